@@ -0,0 +1,117 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hyangah/goup/install"
+)
+
+var (
+	bundleFlags        = flag.NewFlagSet("goup bundle", flag.ExitOnError)
+	bundleVersion      = bundleFlags.String("version", "", "Go version to bundle (e.g. go1.22.3). Defaults to the latest release.")
+	bundleOS           = bundleFlags.String("os", "", "GOOS of the toolchain to bundle. Defaults to the host operating system.")
+	bundleArch         = bundleFlags.String("arch", "", "GOARCH of the toolchain to bundle. Defaults to the host architecture.")
+	bundleGOARM        = bundleFlags.String("goarm", "", "ARM variant (5, 6 or 7) of a GOARCH=arm toolchain. Only a flat -mirror can serve variants; elsewhere the GOARM=6 build is bundled.")
+	bundleMirror       = bundleFlags.String("mirror", "", "Base `URL` of a server to download the toolchain from instead of GOPROXY. Defaults to $GOUP_MIRROR.")
+	bundleMirrorLayout = bundleFlags.String("mirror-layout", install.MirrorFlat, "Layout of the -mirror: flat, for <mirror>/v0.0.1-<version>.<os>-<arch>.zip, or proxy, for a module proxy.")
+	bundleSumDB        = bundleFlags.String("checksum-db", "", "Checksum `database` to verify the toolchain against, as in GOSUMDB: a known name such as sum.golang.org, or <key> [<url>] for a private one, or off. Defaults to $GOSUMDB, or sum.golang.org.")
+	bundleOutput       = bundleFlags.String("o", "", "Bundle `file` to write. Defaults to goup-<version>.<os>-<arch>.zip in the current directory.")
+)
+
+func init() {
+	addQuietFlags(bundleFlags)
+	addColorFlag(bundleFlags)
+	addDirFlag(bundleFlags)
+}
+
+// runBundle implements the bundle command, which downloads and verifies
+// a toolchain like install, but saves it together with its checksum in
+// a bundle file, for goup install -from to install without network
+// access.
+func runBundle(ctx context.Context, args []string) error {
+	bundleFlags.Parse(args)
+	setupOutput()
+	if bundleFlags.NArg() > 0 {
+		return usageError("bundle takes no arguments; use -version")
+	}
+	mirror := *bundleMirror
+	if mirror == "" {
+		mirror = os.Getenv("GOUP_MIRROR")
+	}
+	if os.Getenv("GOPROXY") == "" && mirror == "" {
+		return errors.New("goup bundle needs a module proxy: set GOPROXY, -mirror or GOUP_MIRROR")
+	}
+	sumDB := *bundleSumDB
+	if sumDB == "" {
+		sumDB = os.Getenv("GOSUMDB")
+	}
+
+	// The toolchain is installed into a temporary directory, which
+	// keeps the verified zip.
+	tmp, err := os.MkdirTemp("", "goup-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	// Without an install directory, there is no cache to use.
+	cache, _ := cacheDir()
+	opts := install.Options{
+		Version:      *bundleVersion,
+		Dir:          tmp,
+		GOOS:         *bundleOS,
+		GOARCH:       *bundleArch,
+		GOARM:        *bundleGOARM,
+		Libc:         install.HostLibc(),
+		GOPROXY:      os.Getenv("GOPROXY"),
+		Mirror:       mirror,
+		MirrorLayout: *bundleMirrorLayout,
+		SumDB:        sumDB,
+		GONOSUMDB:    goNoSumDB(),
+		SkipRun:      true,
+		KeepDir:      filepath.Join(tmp, "keep"),
+		CacheDir:     cache,
+		Retries:      3,
+		Client:       httpClient,
+	}
+	if showProgress() {
+		opts.Progress = (&progressBar{w: out}).report
+	}
+	plan, err := install.NewPlan(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if plan.NoSumDB {
+		warnf("the toolchain is not verified against the checksum database, as configured by -checksum-db, GOSUMDB, GONOSUMDB or GOPRIVATE.\n")
+	}
+	dst := *bundleOutput
+	if dst == "" {
+		dst = fmt.Sprintf("goup-%v.%v-%v.zip", plan.Version, plan.GOOS, plan.GOARCH)
+	}
+	logf("Bundling %v for %v/%v...\n", plan.Version, plan.GOOS, plan.GOARCH)
+	if plan.Downloads() && !confirmDownload() {
+		fmt.Fprintln(out, "Stopping go bundling.")
+		return nil
+	}
+	res, err := plan.Run(ctx)
+	if err != nil {
+		return err
+	}
+	m, err := install.ReadManifest(res.Dir)
+	if err != nil {
+		return err
+	}
+	if err := install.WriteBundle(dst, res.Zip, m); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %v (%v, %v). Install it with 'goup install -from %v'.\n", dst, m.Version, m.Checksum, dst)
+	return nil
+}
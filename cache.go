@@ -0,0 +1,59 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var cacheFlags = flag.NewFlagSet("goup cache", flag.ExitOnError)
+
+func init() {
+	addDirFlag(cacheFlags)
+}
+
+// cacheDir returns the directory downloaded toolchain zips, and the list
+// of Go releases, are kept in.
+func cacheDir() (string, error) {
+	root, err := installDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "cache"), nil
+}
+
+// runCache implements the cache command.
+//
+//	goup cache clean
+//
+// removes all downloaded toolchain zips from the cache.
+func runCache(ctx context.Context, args []string) error {
+	cacheFlags.Parse(args)
+	if cacheFlags.NArg() != 1 || cacheFlags.Arg(0) != "clean" {
+		return usageError("usage: goup cache clean")
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+	size, err := dirSize(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Println("The cache is empty.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %v (%s freed).\n", dir, formatBytes(size))
+	return nil
+}
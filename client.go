@@ -0,0 +1,42 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// httpClient is the client used for all requests. It is set up by
+// initHTTPClient.
+var httpClient = http.DefaultClient
+
+// initHTTPClient configures httpClient to use the proxies named by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables and, if
+// GOUP_CACERT names a PEM file, to trust the root certificates in it
+// in addition to the system ones.
+func initHTTPClient() error {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if file := os.Getenv("GOUP_CACERT"); file != "" {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("GOUP_CACERT: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("GOUP_CACERT: no certificates found in %s", file)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	httpClient = &http.Client{Transport: t}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// noColor is set by the -no-color flag.
+var noColor bool
+
+// addColorFlag registers the -no-color flag in fs.
+func addColorFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&noColor, "no-color", false, "Do not color warnings, errors and success messages. Color is also off if NO_COLOR is set or the output is not a terminal.")
+}
+
+// ANSI escape sequences for the colors goup uses.
+const (
+	red    = "\x1b[31m"
+	green  = "\x1b[32m"
+	yellow = "\x1b[33m"
+	reset  = "\x1b[0m"
+)
+
+// colorize returns s in color if it is to be written to w and w is a
+// terminal. Color is never used with -json, whose consumers are
+// programs, nor when NO_COLOR is set (see https://no-color.org).
+func colorize(w io.Writer, color, s string) string {
+	if noColor || jsonOutput || os.Getenv("NO_COLOR") != "" || !isTerminal(w) {
+		return s
+	}
+	return color + s + reset
+}
+
+// warnf prints a warning on stderr.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s %s", colorize(os.Stderr, yellow, "warning:"), fmt.Sprintf(format, args...))
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
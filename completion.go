@@ -0,0 +1,125 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyangah/goup/install"
+)
+
+// commands are the subcommands offered by shell completion.
+var commands = []string{"install", "bundle", "uninstall", "prune", "list", "list-remote", "use", "exec", "verify", "doctor", "cache", "self-update", "version", "completion", "help"}
+
+// The completion scripts call the hidden __complete command to list the
+// installed versions and the published releases.
+
+const bashCompletion = `# bash completion for goup. Load it with
+#	source <(goup completion bash)
+_goup() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd="${COMP_WORDS[1]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+		return
+	fi
+	case "$cmd" in
+	use|exec|uninstall)
+		COMPREPLY=($(compgen -W "$(goup __complete installed 2>/dev/null)" -- "$cur")) ;;
+	verify)
+		[ "$prev" = -version ] && COMPREPLY=($(compgen -W "$(goup __complete installed 2>/dev/null)" -- "$cur")) ;;
+	install|-*)
+		[ "$prev" = -version ] && COMPREPLY=($(compgen -W "$(goup __complete releases 2>/dev/null)" -- "$cur")) ;;
+	cache)
+		COMPREPLY=($(compgen -W "clean" -- "$cur")) ;;
+	completion)
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")) ;;
+	esac
+}
+complete -F _goup goup
+`
+
+// zsh runs the bash completion through its bash compatibility layer.
+const zshCompletion = `# zsh completion for goup. Load it with
+#	source <(goup completion zsh)
+autoload -U +X bashcompinit && bashcompinit
+`
+
+const fishCompletion = `# fish completion for goup. Load it with
+#	goup completion fish | source
+complete -c goup -f
+complete -c goup -n __fish_use_subcommand -a '%s'
+complete -c goup -n '__fish_seen_subcommand_from use exec uninstall' -a '(goup __complete installed 2>/dev/null)'
+complete -c goup -n '__fish_seen_subcommand_from uninstall verify' -o version -x -a '(goup __complete installed 2>/dev/null)'
+complete -c goup -n '__fish_use_subcommand; or __fish_seen_subcommand_from install' -o version -x -a '(goup __complete releases 2>/dev/null)'
+complete -c goup -n '__fish_seen_subcommand_from cache' -a clean
+complete -c goup -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+`
+
+// runCompletion implements the completion command, which prints the
+// completion script for a shell.
+func runCompletion(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: goup completion bash|zsh|fish")
+	}
+	words := strings.Join(commands, " ")
+	switch args[0] {
+	case "bash":
+		fmt.Printf(bashCompletion, words)
+	case "zsh":
+		fmt.Print(zshCompletion)
+		fmt.Printf(bashCompletion, words)
+	case "fish":
+		fmt.Printf(fishCompletion, words)
+	default:
+		return usageError(fmt.Sprintf("unsupported shell %q; want bash, zsh or fish", args[0]))
+	}
+	return nil
+}
+
+// runComplete implements the hidden __complete command used by the
+// completion scripts. It prints the installed versions or the published
+// releases, one per line.
+func runComplete(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError("usage: goup __complete installed|releases")
+	}
+	switch args[0] {
+	case "installed":
+		root, err := installDir()
+		if err != nil {
+			return err
+		}
+		installs, err := install.InstalledVersions(root)
+		if err != nil {
+			return err
+		}
+		for _, in := range installs {
+			if in.GOOS == "" {
+				fmt.Println(in.Version)
+			}
+		}
+	case "releases":
+		// Do not keep the shell waiting on a slow network.
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		cache, err := cacheDir()
+		if err != nil {
+			return err
+		}
+		versions, err := install.Releases(ctx, httpClient, cache, false)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			fmt.Println(v)
+		}
+	default:
+		return usageError(fmt.Sprintf("unknown completion %q", args[0]))
+	}
+	return nil
+}
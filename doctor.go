@@ -0,0 +1,173 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/hyangah/goup/install"
+)
+
+var doctorFlags = flag.NewFlagSet("goup doctor", flag.ExitOnError)
+
+func init() {
+	addDirFlag(doctorFlags)
+	addColorFlag(doctorFlags)
+}
+
+// A finding is the result of one of the checks of goup doctor.
+type finding struct {
+	problem bool   // false for checks that passed
+	area    string // what was checked
+	msg     string
+	fix     string // how to fix the problem, if any
+}
+
+// runDoctor implements the doctor command, which checks the environment
+// for common problems with using the Go toolchains installed by goup.
+func runDoctor(ctx context.Context, args []string) error {
+	doctorFlags.Parse(args)
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+
+	var findings []finding
+	ok := func(area, format string, args ...any) {
+		findings = append(findings, finding{area: area, msg: fmt.Sprintf(format, args...)})
+	}
+	problem := func(area, fix, format string, args ...any) {
+		findings = append(findings, finding{problem: true, area: area, msg: fmt.Sprintf(format, args...), fix: fix})
+	}
+
+	// The install directory.
+	switch fi, err := os.Stat(root); {
+	case errors.Is(err, fs.ErrNotExist):
+		ok("install dir", "%v does not exist yet; goup install creates it", root)
+	case err != nil:
+		problem("install dir", "Choose another directory with -dir or GOINSTALLDIR.", "cannot access %v: %v", root, err)
+	case !fi.IsDir():
+		problem("install dir", "Remove it, or choose another directory with -dir or GOINSTALLDIR.", "%v is not a directory", root)
+	case install.MountedNoExec(root):
+		problem("install dir", "Choose a directory on another file system with -dir or GOINSTALLDIR.", "%v is on a file system mounted noexec, so the toolchains in it cannot run", root)
+	default:
+		ok("install dir", "%v", root)
+	}
+
+	// The platform.
+	hostOS, hostArch, err := install.HostOSArch()
+	switch {
+	case err != nil:
+		problem("platform", "", "cannot tell the host platform: %v", err)
+	case hostArch != runtime.GOARCH:
+		problem("platform", fmt.Sprintf("Install the %v/%v build of goup.", hostOS, hostArch),
+			"goup is a %v/%v program running under Rosetta translation on a %v/%v machine", runtime.GOOS, runtime.GOARCH, hostOS, hostArch)
+	default:
+		ok("platform", "%v/%v", hostOS, hostArch)
+	}
+	if hostOS == "linux" {
+		switch libc := install.HostLibc(); libc {
+		case "":
+			ok("libc", "unknown; no dynamic loader found in /lib")
+		case "musl":
+			ok("libc", "musl; the standard Go builds are linked against glibc and may need a compatibility package, such as gcompat")
+		default:
+			ok("libc", "%v", libc)
+		}
+	}
+
+	// The installed toolchains.
+	installs, err := install.InstalledVersions(root)
+	if err != nil {
+		problem("toolchains", "", "cannot list the installed toolchains: %v", err)
+	}
+	for _, in := range installs {
+		if in.GOOS != "" {
+			ok("toolchains", "%v is for %v/%v, and does not run on this machine", in.Version, in.GOOS, in.GOARCH)
+			continue
+		}
+		if _, err := install.GoVersion(install.GoBinary(in.Dir)); err != nil {
+			problem("toolchains", fmt.Sprintf("Reinstall it with goup install -force %v.", filepath.Base(in.Dir)), "%v does not run: %v", in.Dir, err)
+			continue
+		}
+		ok("toolchains", "%v in %v", in.Version, in.Dir)
+	}
+	if len(installs) == 0 && err == nil {
+		ok("toolchains", "none installed")
+	}
+
+	// PATH and the go command found in it.
+	var want string // the go command that should be found in PATH
+	if dir, err := install.SelectedDir(root); err == nil && dir != "" {
+		want = filepath.Join(root, "bin", "go")
+		if !inPath(filepath.Dir(want)) {
+			problem("PATH", fmt.Sprintf("Add %v to the front of PATH.", filepath.Dir(want)), "goup use selected %v, but %v is not in PATH", filepath.Base(dir), filepath.Dir(want))
+		}
+	} else if len(installs) == 1 {
+		want = install.GoBinary(installs[0].Dir)
+	}
+	switch gobin, err := exec.LookPath("go"); {
+	case err != nil:
+		fix := "Select a version with goup use goX.Y.Z, and add its bin directory to PATH."
+		if len(installs) == 0 {
+			fix = "Install Go with goup install."
+		}
+		problem("PATH", fix, "no go command in PATH")
+	case want != "" && shadowingGo(want) != "":
+		problem("PATH", fmt.Sprintf("Move %v before %v in PATH, or uninstall the other Go.", filepath.Dir(want), filepath.Dir(gobin)),
+			"the go command in PATH is %v, not %v", gobin, want)
+	default:
+		v, err := install.GoVersion(gobin)
+		if err != nil {
+			problem("PATH", "Fix or remove it, or put a working go command before it in PATH.", "%v does not run: %v", gobin, err)
+		} else {
+			ok("PATH", "go is %v (%v)", gobin, v)
+		}
+	}
+
+	// The environment. The go command does not need GOROOT, but some
+	// tools do, and install -set-goroot sets it to the installed
+	// toolchain; it is only wrong if it is not the active one.
+	active, _ := install.ActiveVersion(root)
+	goroot := os.Getenv("GOROOT")
+	_, err = os.Stat(install.GoBinary(goroot))
+	switch {
+	case goroot == "":
+		ok("environment", "GOROOT is not set")
+	case err != nil:
+		problem("environment", "Unset GOROOT, or set it to the active toolchain; the go command finds its GOROOT without help.", "GOROOT is set to %v, which has no go command", goroot)
+	case active.Dir != "" && !sameFile(goroot, active.Dir):
+		problem("environment", fmt.Sprintf("Set GOROOT to %v, or unset it; the go command finds its GOROOT without help.", active.Dir),
+			"GOROOT is set to %v, not to the active toolchain, so every go command uses that toolchain's standard library", goroot)
+	default:
+		ok("environment", "GOROOT is set to %v", goroot)
+	}
+
+	problems := 0
+	for _, f := range findings {
+		status := "ok"
+		if f.problem {
+			status = "PROBLEM"
+			problems++
+		}
+		fmt.Printf("%-8s %-12s %s\n", status, f.area+":", f.msg)
+		if f.fix != "" {
+			fmt.Printf("%21s %s\n", "", f.fix)
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("found %d problems", problems)
+	}
+	fmt.Println("\nNo problems found.")
+	return nil
+}
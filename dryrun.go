@@ -0,0 +1,44 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hyangah/goup/install"
+)
+
+// printDryRun prints what p would do without downloading or writing
+// anything. It returns the URL the toolchain would be downloaded from,
+// or "" if none is available.
+func printDryRun(ctx context.Context, p *install.Plan) string {
+	// Source tells which build is available, so ask it first.
+	url, err := p.Source(ctx)
+	fmt.Fprintf(out, "Version:    %v\n", p.Version)
+	if p.GOARM != "" {
+		fmt.Fprintf(out, "Platform:   %v/%v (GOARM=%v)\n", p.GOOS, p.GOARCH, p.GOARM)
+	} else {
+		fmt.Fprintf(out, "Platform:   %v/%v\n", p.GOOS, p.GOARCH)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Download:   not available (%v)\n", err)
+	} else {
+		fmt.Fprintf(out, "Download:   %v\n", url)
+	}
+	fmt.Fprintf(out, "Install at: %v\n", p.Dir)
+	switch _, err := os.Stat(p.Dir); {
+	case err != nil:
+		fmt.Fprintf(out, "Existing:   none\n")
+	case p.Installed():
+		fmt.Fprintf(out, "Existing:   %v is already installed; it would only be reinstalled with -force\n", p.Version)
+	case !install.IsManaged(p.Dir):
+		fmt.Fprintf(out, "Existing:   %v exists and was not installed by goup; the installation would fail\n", p.Dir)
+	default:
+		fmt.Fprintf(out, "Existing:   %v exists and would be overwritten\n", p.Dir)
+	}
+	return url
+}
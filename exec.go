@@ -0,0 +1,95 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hyangah/goup/install"
+)
+
+var execFlags = flag.NewFlagSet("goup exec", flag.ExitOnError)
+
+func init() {
+	addDirFlag(execFlags)
+}
+
+// An exitStatus is the exit status of a command run by goup exec, with
+// which goup exits in turn.
+type exitStatus int
+
+func (s exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+// runExec implements the exec command.
+//
+//	goup exec goX.Y.Z [--] command [args...]
+//
+// runs command with the bin directory of the installed version first in
+// PATH and GOROOT set to it, without changing the active version.
+// GOTOOLCHAIN=local keeps the go command from switching to another
+// toolchain.
+func runExec(ctx context.Context, args []string) error {
+	execFlags.Parse(args)
+	args = execFlags.Args()
+	if len(args) > 1 && args[1] == "--" {
+		args = append(args[:1], args[2:]...)
+	}
+	if len(args) < 2 {
+		return usageError("usage: goup exec goX.Y.Z [--] command [args...]")
+	}
+	version, name := args[0], args[1]
+	if version == "tip" {
+		version = install.Tip
+	}
+	if err := install.ValidateVersion(version); err != nil {
+		return err
+	}
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+	dir := install.VersionDir(root, version)
+	if _, err := os.Stat(install.GoBinary(dir)); err != nil {
+		return fmt.Errorf("%v is not installed; install it with 'goup install -version %v'", version, version)
+	}
+
+	// exec.Command looks commands up in the PATH of goup, not in the
+	// one given to the command, so look in the toolchain first.
+	bin := filepath.Join(dir, "bin")
+	path := name
+	if !strings.ContainsAny(name, `/\`) {
+		exe := name
+		if runtime.GOOS == "windows" && filepath.Ext(exe) == "" {
+			exe += ".exe"
+		}
+		if _, err := os.Stat(filepath.Join(bin, exe)); err == nil {
+			path = filepath.Join(bin, exe)
+		} else if path, err = exec.LookPath(name); err != nil {
+			return err
+		}
+	}
+	cmd := exec.Command(path, args[2:]...)
+	cmd.Env = append(os.Environ(),
+		"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"GOROOT="+dir,
+		"GOTOOLCHAIN=local",
+	)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// An interrupt reaches the command too; goup waits for it to exit.
+	err = cmd.Run()
+	var eerr *exec.ExitError
+	if errors.As(err, &eerr) && eerr.ExitCode() >= 0 {
+		return exitStatus(eerr.ExitCode())
+	}
+	return err
+}
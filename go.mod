@@ -2,4 +2,7 @@ module github.com/hyangah/goup
 
 go 1.21.0
 
-require golang.org/x/net v0.9.0
+require (
+	golang.org/x/mod v0.12.0
+	golang.org/x/net v0.9.0
+)
@@ -0,0 +1,151 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A bundle is a zip file holding a toolchain module zip together with a
+// bundleManifestFile that records its checksum. It carries a verified
+// toolchain to machines that cannot reach a proxy or the checksum
+// database, where it is installed with Options.From.
+const bundleManifestFile = "goup-bundle.json"
+
+// BundleManifest describes the toolchain in a bundle.
+type BundleManifest struct {
+	Version   string    `json:"version"`  // Go version, e.g. go1.22.3
+	Module    string    `json:"module"`   // toolchain module version, e.g. v0.0.1-go1.22.3.linux-amd64
+	Checksum  string    `json:"checksum"` // h1: hash of the module zip
+	GOOS      string    `json:"goos"`
+	GOARCH    string    `json:"goarch"`
+	GOARM     string    `json:"goarm,omitempty"` // of an arm toolchain variant, see Plan.GOARM
+	Libc      string    `json:"libc,omitempty"`  // "musl" for a musl build, see Plan.Libc
+	URL       string    `json:"url"`             // where the module zip was downloaded from
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WriteBundle writes a bundle to dst holding the toolchain module zip at
+// file, which was installed as recorded by m.
+func WriteBundle(dst, file string, m *Manifest) (err error) {
+	bm := BundleManifest{
+		Version:   m.Version,
+		Module:    m.Module,
+		Checksum:  m.Checksum,
+		GOOS:      m.GOOS,
+		GOARCH:    m.GOARCH,
+		GOARM:     m.GOARM,
+		Libc:      m.Libc,
+		URL:       m.URL,
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(bm, "", "\t")
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	zw := zip.NewWriter(tmp)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: bundleManifestFile, Method: zip.Deflate, Modified: bm.CreatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	// The module zip is compressed already.
+	w, err = zw.CreateHeader(&zip.FileHeader{Name: m.Module + ".zip", Method: zip.Store, Modified: bm.CreatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// readBundle returns the manifest of the bundle at file, or nil if file
+// is not a bundle.
+func readBundle(file string) (*BundleManifest, error) {
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.Name != bundleManifestFile {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		bm := new(BundleManifest)
+		if err := json.NewDecoder(r).Decode(bm); err != nil {
+			return nil, fmt.Errorf("%s: parsing %s: %v", file, bundleManifestFile, err)
+		}
+		if bm.Module == "" || bm.Checksum == "" {
+			return nil, fmt.Errorf("%s: %s names no module or checksum", file, bundleManifestFile)
+		}
+		return bm, nil
+	}
+	return nil, nil
+}
+
+// extractBundle copies the module zip in the bundle at file into a
+// temporary file in dir, and returns its path.
+func extractBundle(file, dir string, bm *BundleManifest) (string, error) {
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer z.Close()
+	r, err := z.Open(bm.Module + ".zip")
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", file, err)
+	}
+	defer r.Close()
+	tmp, err := os.CreateTemp(dir, ".goup-bundle-*.zip")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The cache (see Options.CacheDir) uses the layout of the go command's
+// module download cache: the zip of each toolchain module version is
+// stored as golang.org/toolchain/@v/<module>.zip, next to a .ziphash
+// file holding its h1: hash, which is only written once the zip has
+// been verified and installed.
+
+// cacheFile returns the path of the cached zip of the variant v of p's
+// toolchain module.
+func (p *Plan) cacheFile(v variant) string {
+	return filepath.Join(p.opts.CacheDir, filepath.FromSlash(gotoolchainModule), "@v", p.module+v.suffix()+".zip")
+}
+
+// cached returns the most preferred variant of the toolchain of p that
+// is in the cache, if any.
+func (p *Plan) cached() (variant, bool) {
+	if p.opts.CacheDir == "" {
+		return variant{}, false
+	}
+	for _, v := range p.candidates() {
+		if _, err := os.Stat(p.cacheFile(v) + "hash"); err == nil {
+			return v, true
+		}
+	}
+	return variant{}, false
+}
+
+// isCached reports whether a toolchain zip of p is in the cache.
+func (p *Plan) isCached() bool {
+	_, ok := p.cached()
+	return ok
+}
+
+// installCached installs the toolchain from its cached zip. If the zip
+// does not match its recorded hash, it is removed from the cache and the
+// toolchain is downloaded again.
+func (p *Plan) installCached(ctx context.Context, m *Manifest) error {
+	v, _ := p.cached()
+	file := p.cacheFile(v)
+	if z, ok := p.openCached(file); ok {
+		defer z.Close()
+		p.setVariant(v, m)
+		if err := p.installZip(ctx, &z.Reader, file, "", "file://"+filepath.ToSlash(file), m); err != nil {
+			return err
+		}
+		if p.opts.KeepDir != "" {
+			return p.keepZip(file)
+		}
+		return nil
+	}
+	os.Remove(file + "hash")
+	os.Remove(file)
+	return p.fetch(ctx, m)
+}
+
+// openCached opens the cached zip at file, if it matches its recorded hash.
+func (p *Plan) openCached(file string) (*zip.ReadCloser, bool) {
+	want, err := os.ReadFile(file + "hash")
+	if err != nil {
+		return nil, false
+	}
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, false
+	}
+	if sum, err := hashZip(&z.Reader, gotoolchainModule, p.module, toolchainPrefix(&z.Reader, gotoolchainModule+"@"+p.module+"/")); err != nil || sum != strings.TrimSpace(string(want)) {
+		z.Close()
+		return nil, false
+	}
+	return z, true
+}
+
+// saveToCache copies the verified toolchain zip at file, the variant v,
+// of which sum is the h1: hash, into the cache.
+func (p *Plan) saveToCache(file, sum string, v variant) error {
+	dst := p.cacheFile(v)
+	if err := copyFile(dst, file); err != nil {
+		return err
+	}
+	return os.WriteFile(dst+"hash", []byte(sum+"\n"), 0o644)
+}
+
+// keepZip copies the installed toolchain zip at file into
+// Options.KeepDir.
+func (p *Plan) keepZip(file string) error {
+	dst := filepath.Join(p.opts.KeepDir, p.module+variant{p.GOARM, p.Libc}.suffix()+".zip")
+	if err := copyFile(dst, file); err != nil {
+		return fmt.Errorf("keeping the toolchain zip: %v", err)
+	}
+	p.kept = dst
+	return nil
+}
+
+// copyFile copies the file src to dst, creating its directory if
+// needed. dst is replaced atomically, so that it is never incomplete.
+func copyFile(dst, src string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+	"golang.org/x/mod/sumdb/note"
+)
+
+const (
+	// sumdbName and sumdbKey identify the Go checksum database,
+	// as in the go command's default GOSUMDB setting.
+	sumdbName = "sum.golang.org"
+	sumdbKey  = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"
+)
+
+// checksumDB is a checksum database to verify toolchains against.
+type checksumDB struct {
+	name string // e.g. sum.golang.org
+	key  string // verifier key, e.g. sumdbKey
+	url  string // base URL, e.g. https://sum.golang.org
+}
+
+var defaultChecksumDB = &checksumDB{name: sumdbName, key: sumdbKey, url: "https://" + sumdbName}
+
+// parseChecksumDB parses a checksum database setting in the syntax of
+// GOSUMDB: the name of a database the go command knows, or a verifier
+// key <name>+<hash>+<key>, optionally followed by the URL to reach it at
+// if that is not https://<name>. An empty setting is sum.golang.org.
+// The value off is handled by the caller.
+func parseChecksumDB(s string) (*checksumDB, error) {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return defaultChecksumDB, nil
+	}
+	if len(f) > 2 {
+		return nil, fmt.Errorf("invalid checksum database %q: want <name or key> [<url>]", s)
+	}
+	db := &checksumDB{}
+	switch {
+	case f[0] == sumdbName:
+		db.name, db.key = sumdbName, sumdbKey
+	case f[0] == "sum.golang.google.cn":
+		// A mirror of sum.golang.org reachable from China.
+		db.name, db.key, db.url = sumdbName, sumdbKey, "https://sum.golang.google.cn"
+	default:
+		v, err := note.NewVerifier(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum database %q: neither sum.golang.org nor a verifier key: %v", f[0], err)
+		}
+		db.name, db.key = v.Name(), f[0]
+	}
+	if len(f) == 2 {
+		u, err := url.Parse(f[1])
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid checksum database URL %q: want an http or https URL", f[1])
+		}
+		db.url = strings.TrimSuffix(f[1], "/")
+	}
+	if db.url == "" {
+		db.url = "https://" + db.name
+	}
+	return db, nil
+}
+
+// verifyChecksum checks that got, the module zip hash of a downloaded
+// archive (see hashZip), matches the hash recorded in the checksum
+// database f.sumdb, or the Go checksum database if it is nil, for
+// mod@version.
+func (f *fetcher) verifyChecksum(ctx context.Context, got, mod, version string) error {
+	db := f.sumdb
+	if db == nil {
+		db = defaultChecksumDB
+	}
+	client := sumdb.NewClient(&sumdbOps{ctx: ctx, f: f, db: db})
+	lines, err := client.Lookup(mod, version)
+	if err != nil {
+		return fmt.Errorf("looking up %s@%s in the checksum database %s (%s): %v", mod, version, db.name, db.url, err)
+	}
+	prefix := mod + " " + version + " "
+	for _, line := range lines {
+		if want, ok := strings.CutPrefix(line, prefix); ok {
+			if got != want {
+				return fmt.Errorf("checksum mismatch for %s@%s:\n\tdownloaded: %s\n\t%s: %s", mod, version, got, db.name, want)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no checksum for %s@%s", db.name, mod, version)
+}
+
+// hashZip returns the h1: hash of archive as a module zip of mod@version,
+// with the directory strip left out of the entry paths (see WriteZip).
+// Archive entries that lack the mod@version/ prefix required of module
+// zips are hashed as if they had it.
+func hashZip(archive *zip.Reader, mod, version, strip string) (string, error) {
+	prefix := mod + "@" + version + "/"
+	var names []string
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := strings.TrimPrefix(f.Name, strip)
+		if !strings.HasPrefix(name, prefix) {
+			name = prefix + name
+		}
+		names = append(names, name)
+		files[name] = f
+	}
+	return dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		return files[name].Open()
+	})
+}
+
+// validateChecksum reports an error if sum is not a checksum accepted
+// by Options.Checksum.
+func validateChecksum(sum string) error {
+	if h, ok := strings.CutPrefix(sum, "sha256:"); ok {
+		if b, err := hex.DecodeString(h); err == nil && len(b) == sha256.Size {
+			return nil
+		}
+	} else if h, ok := strings.CutPrefix(sum, "h1:"); ok {
+		if b, err := base64.StdEncoding.DecodeString(h); err == nil && len(b) == sha256.Size {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid checksum %q: want sha256:<64 hex digits> or h1:<base64 module hash>", sum)
+}
+
+// checkPinnedChecksum checks that the toolchain zip stored in file, of
+// which h1 is the module hash, matches want. zipSum is the SHA-256 of
+// file in hex, if known; otherwise file is read to compute it.
+func checkPinnedChecksum(want, h1, zipSum, file string) error {
+	if strings.HasPrefix(want, "h1:") {
+		if h1 != want {
+			return fmt.Errorf("checksum mismatch: the h1: module hash (SHA-256 over the SHA-256 of each file, as in go.sum) of the toolchain zip is %s, want %s", h1, want)
+		}
+		return nil
+	}
+	if zipSum == "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		zipSum = hex.EncodeToString(h.Sum(nil))
+	}
+	if got := "sha256:" + zipSum; !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch: the SHA-256 of the toolchain zip file is %s, want %s", got, want)
+	}
+	return nil
+}
+
+// sumdbOps implements sumdb.ClientOps, fetching from the checksum
+// database over HTTP and keeping the configuration and cache in memory
+// for the duration of the run.
+type sumdbOps struct {
+	ctx context.Context
+	f   *fetcher
+	db  *checksumDB
+
+	mu     sync.Mutex
+	config map[string][]byte
+	cache  map[string][]byte
+}
+
+func (o *sumdbOps) ReadRemote(path string) ([]byte, error) {
+	return o.f.readBody(o.ctx, o.db.url+path)
+}
+
+func (o *sumdbOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.db.key), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.config[file], nil
+}
+
+func (o *sumdbOps) WriteConfig(file string, old, new []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if string(o.config[file]) != string(old) {
+		return sumdb.ErrWriteConflict
+	}
+	if o.config == nil {
+		o.config = make(map[string][]byte)
+	}
+	o.config[file] = new
+	return nil
+}
+
+func (o *sumdbOps) ReadCache(file string) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if data, ok := o.cache[file]; ok {
+		return data, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (o *sumdbOps) WriteCache(file string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cache == nil {
+		o.cache = make(map[string][]byte)
+	}
+	o.cache[file] = data
+}
+
+func (o *sumdbOps) Log(msg string) {}
+
+func (o *sumdbOps) SecurityError(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkDiskSpace reports an error if the volume holding dst does not
+// have enough free space to extract archive.
+func checkDiskSpace(dst string, archive *zip.Reader) error {
+	var need uint64
+	for _, f := range archive.File {
+		need += f.UncompressedSize64
+	}
+	// dst may not exist yet; check the closest existing ancestor.
+	dir := dst
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	have, err := freeSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("checking free disk space in %v: %v", dir, err)
+	}
+	if have < need {
+		const mb = 1 << 20
+		return fmt.Errorf("not enough disk space in %v: need %d MB, have %d MB free", dir, (need+mb-1)/mb, have/mb)
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Version is the version of goup, reported in the User-Agent of its
+// requests. Release builds set it with
+// -ldflags=-X=github.com/hyangah/goup/install.Version=<version>.
+var Version = "devel"
+
+// UserAgent returns the User-Agent header sent with all requests.
+func UserAgent() string {
+	return fmt.Sprintf("goup/%s (%s/%s)", Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// Get returns the body of a successful GET request for u, made with
+// client, or http.DefaultClient if client is nil. Failed requests are
+// retried up to retries times, as for an installation (see
+// Options.Retries). A body of more than maxSize bytes is an error.
+func Get(ctx context.Context, client *http.Client, u string, retries int, maxSize int64) ([]byte, error) {
+	var data []byte
+	err := newFetcher(client, retries, nil).executeRequest(ctx, u, false, func(body io.Reader) error {
+		var err error
+		data, err = io.ReadAll(io.LimitReader(body, maxSize+1))
+		if err == nil && int64(len(data)) > maxSize {
+			err = fmt.Errorf("%s: response is larger than %d bytes", u, maxSize)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// fetcher performs the HTTP requests of an installation.
+type fetcher struct {
+	client   *http.Client
+	retries  int
+	progress ProgressFunc
+	// attemptTimeout, if positive, bounds each request, including
+	// reading its response, separately from the deadline of the whole
+	// operation. An attempt that runs out of it is retried.
+	attemptTimeout time.Duration
+	// maxRate, if positive, limits downloads of toolchain zips to that
+	// many bytes per second.
+	maxRate int64
+	// limiter, if set, limits downloads of toolchain zips instead of
+	// maxRate, shared with other fetchers.
+	limiter *RateLimiter
+	// cacheDir, if set, is where the release list is cached.
+	cacheDir string
+	// sumdb is the checksum database to verify toolchains against, or
+	// nil for the Go checksum database.
+	sumdb *checksumDB
+}
+
+// newFetcher returns a fetcher that uses client, or http.DefaultClient
+// if client is nil.
+func newFetcher(client *http.Client, retries int, progress ProgressFunc) *fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &fetcher{client: client, retries: retries, progress: progress}
+}
+
+func (f *fetcher) readBody(ctx context.Context, u string) ([]byte, error) {
+	var data []byte
+	err := f.executeRequest(ctx, u, false, func(body io.Reader) error {
+		var err error
+		data, err = io.ReadAll(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// executeRequest executes an HTTP GET request for u, then calls the bodyFunc
+// on the response body, if no error occurred. If progress is true, the
+// progress callback of f is called as the body is read.
+//
+// Requests that fail with a connection error or a 5xx status are retried
+// up to f.retries times with exponential backoff.
+func (f *fetcher) executeRequest(ctx context.Context, u string, progress bool, bodyFunc func(body io.Reader) error) (err error) {
+	r, err := f.get(ctx, u, nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(r.Body)
+	var body io.Reader = r.Body
+	if progress {
+		body = f.limit(ctx, body, nil)
+	}
+	if progress && f.progress != nil {
+		p := &progressCounter{stage: StageDownload, total: r.ContentLength, report: f.progress}
+		if err := bodyFunc(p.reader(body)); err != nil {
+			return err
+		}
+		p.finish()
+		return nil
+	}
+	return bodyFunc(body)
+}
+
+// limit returns r limited by f.limiter or to f.maxRate, sharing the
+// limiter *l, if non-nil, between the responses of a resumed download.
+func (f *fetcher) limit(ctx context.Context, r io.Reader, l **RateLimiter) io.Reader {
+	if f.limiter != nil {
+		return &rateReader{ctx: ctx, r: r, limiter: f.limiter}
+	}
+	if f.maxRate <= 0 {
+		return r
+	}
+	var limiter *RateLimiter
+	if l != nil && *l != nil {
+		limiter = *l
+	} else {
+		limiter = NewRateLimiter(f.maxRate)
+		if l != nil {
+			*l = limiter
+		}
+	}
+	return &rateReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+// get performs a GET request for u with the additional header fields,
+// retrying it as described for executeRequest, and returns the response
+// if its status indicates success.
+func (f *fetcher) get(ctx context.Context, u string, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		actx, cancel := f.attemptContext(ctx)
+		r, err := f.do(actx, req)
+		if err == nil {
+			r.Body = &attemptBody{ReadCloser: r.Body, cancel: cancel, err: func(err error) error {
+				return f.attemptError(ctx, actx, err)
+			}}
+			return r, nil
+		}
+		err = f.attemptError(ctx, actx, err)
+		cancel()
+		if !retryable(ctx, err) || attempt >= f.retries {
+			return nil, err
+		}
+		if werr := waitBackoff(ctx, attempt); werr != nil {
+			return nil, retryError(ctx, err, werr)
+		}
+	}
+}
+
+// attemptContext returns the context for one attempt at a request made
+// in ctx, which expires after f.attemptTimeout.
+func (f *fetcher) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.attemptTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, f.attemptTimeout)
+}
+
+// attemptError returns err, the error of an attempt at a request made
+// in actx, wrapped in ErrAttemptTimeout if the attempt ran out of time
+// while ctx, the context of the whole operation, had not.
+func (f *fetcher) attemptError(ctx, actx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(actx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w after %v: %v", ErrAttemptTimeout, f.attemptTimeout, err)
+}
+
+// attemptBody is the body of a response to an attempt, which ends when
+// the body is closed.
+type attemptBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	err    func(error) error
+}
+
+func (b *attemptBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.err(err)
+	}
+	return n, err
+}
+
+func (b *attemptBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// doRequest performs a single request for u with the given method and
+// returns the response if its status indicates success.
+func (f *fetcher) doRequest(ctx context.Context, method, u string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.do(ctx, req)
+}
+
+// do is like doRequest, for an already constructed request.
+func (f *fetcher) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	r, err := ctxhttp.Do(ctx, f.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("ctxhttp.Do(ctx, client, %q): %w", req.URL, err)
+	}
+	if err := responseError(r, req.URL.String(), false); err != nil {
+		closeBody(r.Body)
+		return nil, err
+	}
+	return r, nil
+}
+
+// retryable reports whether a request that failed with err, as returned
+// by do, may succeed if it is retried: that is, if it failed to connect,
+// got a 5xx response or ran out of its attempt timeout, and ctx is not
+// done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var uerr *url.Error
+	return errors.Is(err, ErrServerError) || errors.Is(err, ErrAttemptTimeout) || errors.As(err, &uerr)
+}
+
+// downloadFile writes the body of u to file and returns its SHA-256 in
+// hex, computed as it is written, or "" if some of the file was not
+// hashed. Failed requests are retried as by executeRequest. If the
+// transfer breaks off and the server supports range requests, it is
+// also retried, resuming where it stopped; the server is asked to send
+// the whole file again instead if it changed in the meantime. The
+// caller is expected to verify the checksum of the result.
+func (f *fetcher) downloadFile(ctx context.Context, u string, file *os.File) (string, error) {
+	var (
+		p         *progressCounter
+		limiter   *RateLimiter
+		sum       = &digest{Hash: sha256.New()}
+		n         int64  // bytes written to file
+		resumable bool   // whether the server accepts range requests
+		validator string // identifies the version of the file for If-Range
+	)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return "", err
+		}
+		if n > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", n))
+			if validator != "" {
+				req.Header.Set("If-Range", validator)
+			}
+		}
+		actx, cancel := f.attemptContext(ctx)
+		r, err := f.do(actx, req)
+		err = f.attemptError(ctx, actx, err)
+		retry := retryable(ctx, err)
+		if err == nil {
+			err = f.attemptError(ctx, actx, f.copyBody(actx, file, r, &n, sum, &p, &limiter))
+			if err == nil {
+				cancel()
+				if p != nil {
+					p.finish()
+				}
+				if sum.n != n {
+					return "", nil
+				}
+				return hex.EncodeToString(sum.Sum(nil)), nil
+			}
+			if r.StatusCode == http.StatusOK {
+				resumable = r.Header.Get("Accept-Ranges") == "bytes"
+				validator = r.Header.Get("ETag")
+				if validator == "" || strings.HasPrefix(validator, "W/") {
+					// If-Range requires a strong validator.
+					validator = r.Header.Get("Last-Modified")
+				}
+			}
+			// An attempt that timed out is retried even if it cannot
+			// be resumed.
+			retry = (resumable || errors.Is(err, ErrAttemptTimeout)) && ctx.Err() == nil
+			err = fmt.Errorf("downloading %s: %w", u, err)
+		}
+		cancel()
+		if !retry || attempt >= f.retries {
+			return "", err
+		}
+		if werr := waitBackoff(ctx, attempt); werr != nil {
+			return "", retryError(ctx, err, werr)
+		}
+	}
+}
+
+// A digest is a hash that counts the bytes written to it.
+type digest struct {
+	hash.Hash
+	n int64
+}
+
+func (d *digest) Write(b []byte) (int, error) {
+	d.n += int64(len(b))
+	return d.Hash.Write(b)
+}
+
+// copyBody appends the body of r to file, of which the first *n bytes
+// were already downloaded, and updates *n. What is written is also
+// written to sum. Unless r is the partial response to a range request,
+// file and sum are first reset. *p is the progress reporter for the
+// whole file, created on the first response, and *l its rate limiter.
+func (f *fetcher) copyBody(ctx context.Context, file *os.File, r *http.Response, n *int64, sum *digest, p **progressCounter, l **RateLimiter) error {
+	defer closeBody(r.Body)
+	if r.StatusCode == http.StatusPartialContent {
+		if want := fmt.Sprintf("bytes %d-", *n); !strings.HasPrefix(r.Header.Get("Content-Range"), want) {
+			return fmt.Errorf("unexpected Content-Range %q, want %q...", r.Header.Get("Content-Range"), want)
+		}
+	} else {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		*n = 0
+		sum.Reset()
+		sum.n = 0
+		if f.progress != nil {
+			*p = &progressCounter{stage: StageDownload, total: r.ContentLength, report: f.progress}
+		}
+	}
+	body := f.limit(ctx, r.Body, l)
+	if *p != nil {
+		body = (*p).reader(body)
+	}
+	written, err := io.Copy(file, io.TeeReader(body, sum))
+	*n += written
+	return err
+}
+
+// retryError returns err, the error of the last attempt at a request,
+// noting that it was not retried because the deadline of ctx was too
+// close, if waitBackoff failed with werr for that reason.
+func retryError(ctx context.Context, err, werr error) error {
+	if ctx.Err() == nil && errors.Is(werr, context.DeadlineExceeded) {
+		return fmt.Errorf("%w; not retrying, as the deadline is too close", err)
+	}
+	return err
+}
+
+// waitBackoff sleeps before retry number attempt+1, using exponential
+// backoff with jitter. It returns an error without sleeping if ctx would
+// expire before the wait is over.
+func waitBackoff(ctx context.Context, attempt int) error {
+	const maxBackoff = 30 * time.Second
+	d := maxBackoff
+	if attempt < 6 {
+		d = 500 * time.Millisecond << attempt
+	}
+	d += time.Duration(rand.Int63n(int64(d / 2)))
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Errors that downloads fail with, wrapped in an error that describes
+// the failed request. Use errors.Is to test for them.
+var (
+	// ErrNotFound reports a 404 or 410 response.
+	ErrNotFound = errors.New("not found")
+	// ErrNotFetched reports a 404 or 410 response from a proxy that
+	// does not fetch modules it does not have yet.
+	ErrNotFetched = errors.New("not fetched")
+	// ErrTimeout reports a proxy timing out fetching the module.
+	ErrTimeout = errors.New("timeout")
+	// ErrAttemptTimeout reports a request that did not complete within
+	// Options.AttemptTimeout.
+	ErrAttemptTimeout = errors.New("attempt timed out")
+	// ErrServerError reports a 5xx response.
+	ErrServerError = errors.New("server error")
+	// ErrClientError reports a 4xx response other than 404 and 410.
+	ErrClientError = errors.New("client error")
+)
+
+// maxDrain bounds how much of an unread response body closeBody reads.
+// Reading a longer one costs more than a new connection.
+const maxDrain = 64 << 10
+
+// closeBody closes the response body b after reading what is left of it,
+// up to maxDrain bytes, so that the connection can be reused for the
+// next request, such as a retry.
+func closeBody(b io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(b, maxDrain))
+	b.Close()
+}
+
+// maxErrorBody is the length of the response body quoted in the errors
+// returned by responseError.
+const maxErrorBody = 512
+
+// responseError translates the status code of the response r to the
+// request for u to an appropriate error. A 304 response, which only
+// answers a conditional request, is not an error.
+func responseError(r *http.Response, u string, fetchDisabled bool) error {
+	if 200 <= r.StatusCode && r.StatusCode < 300 || r.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxErrorBody+1))
+	if err != nil {
+		return fmt.Errorf("%s returned %s; reading the body: %v", u, r.Status, err)
+	}
+	body := strings.TrimSpace(string(data))
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "..."
+	}
+	var kind error
+	switch {
+	case 500 <= r.StatusCode:
+		kind = ErrServerError
+	case r.StatusCode == http.StatusNotFound,
+		r.StatusCode == http.StatusGone:
+		switch {
+		case strings.Contains(body, "fetch timed out"):
+			kind = ErrTimeout
+		case fetchDisabled:
+			kind = ErrNotFetched
+		default:
+			kind = ErrNotFound
+		}
+	case 400 <= r.StatusCode:
+		kind = ErrClientError
+	default:
+		return fmt.Errorf("%s returned unexpected status %s", u, r.Status)
+	}
+	if body == "" {
+		return fmt.Errorf("%w: %s returned %s", kind, u, r.Status)
+	}
+	return fmt.Errorf("%w: %s returned %s: %q", kind, u, r.Status, body)
+}
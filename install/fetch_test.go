@@ -0,0 +1,179 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testToolchainZip is a small archive shaped like a toolchain.
+func testToolchainZip(t *testing.T) []byte {
+	return zipData(t,
+		zipEntry{name: "go/VERSION", body: "go1.22.3\ntime 2024-05-01T19:59:46Z\n"},
+		zipEntry{name: "go/bin/go", body: strings.Repeat("go", 4096), mode: 0o755},
+	)
+}
+
+// serveZip returns a server that serves data at /go.zip, with its
+// handler wrapped by wrap, if non-nil, and the requests it received.
+func serveZip(t *testing.T, data []byte, wrap func(n int, w http.ResponseWriter, r *http.Request) bool) (*httptest.Server, func() []*http.Request) {
+	var (
+		mu   sync.Mutex
+		reqs []*http.Request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r)
+		n := len(reqs)
+		mu.Unlock()
+		if r.URL.Path != "/go.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		if wrap != nil && wrap(n, w, r) {
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "go.zip", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return reqs
+	}
+}
+
+func TestReadZip(t *testing.T) {
+	srv, _ := serveZip(t, testToolchainZip(t), nil)
+	archive, err := ReadZip(context.Background(), srv.Client(), srv.URL+"/go.zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkToolchainZip(archive, "go/"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadZipErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page.zip" {
+			io.WriteString(w, "<html>not a zip</html>")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	if _, err := ReadZip(context.Background(), srv.Client(), srv.URL+"/missing.zip", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadZip of a missing zip: %v, want ErrNotFound", err)
+	}
+	if _, err := ReadZip(context.Background(), srv.Client(), srv.URL+"/page.zip", nil); err == nil || !strings.Contains(err.Error(), "did not return a zip file") {
+		t.Errorf("ReadZip of a page: %v, want an error saying it is not a zip", err)
+	}
+}
+
+func TestDownloadZip(t *testing.T) {
+	data := testToolchainZip(t)
+	srv, _ := serveZip(t, data, nil)
+	var reported int64
+	z, err := DownloadZip(context.Background(), srv.Client(), srv.URL+"/go.zip", func(stage string, done, total int64) {
+		reported = done
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkToolchainZip(&z.Reader, "go/"); err != nil {
+		t.Error(err)
+	}
+	if h := sha256.Sum256(data); z.sha256 != hex.EncodeToString(h[:]) {
+		t.Errorf("sha256 = %s, want %x", z.sha256, h)
+	}
+	if reported != int64(len(data)) {
+		t.Errorf("progress reported %d bytes, want %d", reported, len(data))
+	}
+	path := z.path
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("Close left %s behind", path)
+	}
+}
+
+func TestDownloadZipRetry(t *testing.T) {
+	data := testToolchainZip(t)
+	srv, reqs := serveZip(t, data, func(n int, w http.ResponseWriter, r *http.Request) bool {
+		if n == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+	z, err := newFetcher(srv.Client(), 1, nil).downloadZip(context.Background(), srv.URL+"/go.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+	if got := len(reqs()); got != 2 {
+		t.Errorf("made %d requests, want 2", got)
+	}
+
+	// Without retries, the first 503 is the answer.
+	srv, _ = serveZip(t, data, func(n int, w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return true
+	})
+	if _, err := DownloadZip(context.Background(), srv.Client(), srv.URL+"/go.zip", nil); !errors.Is(err, ErrServerError) {
+		t.Errorf("DownloadZip: %v, want ErrServerError", err)
+	}
+}
+
+func TestDownloadZipResume(t *testing.T) {
+	data := testToolchainZip(t)
+	half := len(data) / 2
+	srv, reqs := serveZip(t, data, func(n int, w http.ResponseWriter, r *http.Request) bool {
+		if n > 1 {
+			return false
+		}
+		// Break off the first response halfway.
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data[:half])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	})
+	z, err := newFetcher(srv.Client(), 1, nil).downloadZip(context.Background(), srv.URL+"/go.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+	if h := sha256.Sum256(data); z.sha256 != hex.EncodeToString(h[:]) {
+		t.Errorf("sha256 = %s, want %x", z.sha256, h)
+	}
+	rs := reqs()
+	if len(rs) != 2 {
+		t.Fatalf("made %d requests, want 2", len(rs))
+	}
+	if got, want := rs[1].Header.Get("Range"), "bytes="+strconv.Itoa(half)+"-"; got != want {
+		t.Errorf("second request has Range %q, want %q", got, want)
+	}
+	if got := rs[1].Header.Get("If-Range"); got != `"v1"` {
+		t.Errorf("second request has If-Range %q, want %q", got, `"v1"`)
+	}
+}
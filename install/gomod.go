@@ -0,0 +1,125 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// FindGoMod returns the path of the go.mod file of the module containing
+// dir, looking in dir and its parents as the go command does.
+func FindGoMod(dir string) (string, error) {
+	file, err := findUp(dir, "go.mod")
+	if err == nil && file == "" {
+		err = fmt.Errorf("go.mod file not found in %v or any of its parents", dir)
+	}
+	return file, err
+}
+
+// FindGoVersionFile returns the path of the .go-version file, as read by
+// goenv and asdf, in dir or the nearest of its parents, or "" if there
+// is none.
+func FindGoVersionFile(dir string) (string, error) {
+	return findUp(dir, ".go-version")
+}
+
+// findUp returns the path of the file called name in dir or the nearest
+// of its parents, or "" if there is none.
+func findUp(dir, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// GoVersionFileVersion returns the Go version pinned by the .go-version
+// file at file: its first line that is not blank or a # comment, with
+// or without the go prefix, as in 1.22.3 or go1.22.3.
+func GoVersionFileVersion(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		version := line
+		if !strings.HasPrefix(version, "go") {
+			version = "go" + version
+		}
+		if err := ValidateVersion(version); err != nil {
+			return "", fmt.Errorf("%v: invalid Go version %q: want X.Y.Z or goX.Y.Z", file, line)
+		}
+		return version, nil
+	}
+	return "", fmt.Errorf("%v names no Go version", file)
+}
+
+// GoModVersion returns the Go toolchain version required by the go.mod
+// file at file, following the go command's GOTOOLCHAIN=auto logic: the
+// newer of the release named by the go line and the toolchain line, if
+// any.
+func GoModVersion(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	f, err := modfile.Parse(file, data, nil)
+	if err != nil {
+		return "", err
+	}
+	if f.Go == nil {
+		return "", fmt.Errorf("%v has no go line", file)
+	}
+	version := goLineRelease(f.Go.Version)
+	if err := ValidateVersion(version); err != nil {
+		return "", fmt.Errorf("%v: go %v: %v", file, f.Go.Version, err)
+	}
+	if f.Toolchain != nil && f.Toolchain.Name != "default" {
+		tc := f.Toolchain.Name
+		if err := ValidateVersion(tc); err != nil {
+			return "", fmt.Errorf("%v: toolchain %v: %v", file, tc, err)
+		}
+		if CompareVersions(tc, version) > 0 {
+			version = tc
+		}
+	}
+	return version, nil
+}
+
+// goLineRelease returns the first Go release implementing the language
+// version v of a go line. Starting with Go 1.21, "go 1.N" means the
+// release go1.N.0; before, it was named go1.N.
+func goLineRelease(v string) string {
+	if major, minor, ok := strings.Cut(v, "."); ok && major == "1" {
+		if n, err := strconv.Atoi(minor); err == nil && n >= 21 {
+			return "go" + v + ".0"
+		}
+	}
+	return "go" + v
+}
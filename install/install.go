@@ -0,0 +1,760 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package install downloads and installs Go toolchains.
+//
+// Each Go version is installed in its own directory under a root
+// directory, e.g. ~/.go/go1.22.3, as unpacked from the golang.org/toolchain
+// module zip that the go command itself uses for toolchain switching.
+package install
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+const (
+	// We download golang.org/toolchain version v0.0.1-<gotoolchain>.<goos>-<goarch>.
+	// If the 0.0.1 indicates anything at all, its the version of the toolchain packaging:
+	// if for some reason we needed to change the way toolchains are packaged into
+	// module zip files in a future version of Go, we could switch to v0.0.2 and then
+	// older versions expecting the old format could use v0.0.1 and newer versions
+	// would use v0.0.2. Of course, then we'd also have to publish two of each
+	// module zip file. It's not likely we'll ever need to change this.
+	gotoolchainModule  = "golang.org/toolchain"
+	gotoolchainVersion = "v0.0.1"
+
+	// installerSuffix marks the Go version of a bootstrap toolchain,
+	// which only consists of a go command that installs another version.
+	installerSuffix = "-installer"
+	// bootstrapVersion is the Go version of the bootstrap toolchain
+	// installed when GOPROXY is empty.
+	bootstrapVersion = "go1.21.0beta1" + installerSuffix
+)
+
+// toolchainArchiveName returns the golang.org/toolchain module version
+// holding the given Go version for goos/goarch, such as
+// v0.0.1-go1.22.3.linux-amd64 or, for the bootstrap toolchain,
+// v0.0.1-go1.21.0beta1-installer.linux-amd64. Pre-releases are named as
+// usual (go1.23rc1, go1.22beta1). The version is not normalized: Go 1.21
+// and later publish their first release as goX.Y.0, so a patch-less
+// goX.Y release has no toolchain module.
+func toolchainArchiveName(version, goos, goarch string) string {
+	return fmt.Sprintf("%v-%v.%v-%v", gotoolchainVersion, version, goos, goarch)
+}
+
+// Options configure an installation.
+type Options struct {
+	// Version is the Go version to install, e.g. go1.22.3.
+	// If empty, the latest release is installed.
+	Version string
+	// Unstable makes an empty Version select the latest beta or release
+	// candidate if it is newer than the latest release.
+	Unstable bool
+	// Dir is the root directory; the toolchain is installed in
+	// VersionDir(Dir, Version), or PlatformDir(Dir, Version, GOOS,
+	// GOARCH) if it is for another platform than the host.
+	Dir string
+	// GOOS and GOARCH select the platform of the toolchain. They default
+	// to the host platform, as reported by HostOSArch. Toolchains for
+	// other platforms need GOPROXY or a mirror: the bootstrap toolchain
+	// only switches to the requested version by running on the host.
+	GOOS, GOARCH string
+	// GOARM, if set, selects the 32-bit ARM variant, 5, 6 or 7, of a
+	// GOARCH=arm toolchain. Only flat mirrors (see MirrorFlat) can serve
+	// variants; elsewhere there is a single arm build, for GOARM=6,
+	// which is installed instead.
+	GOARM string
+	// Libc, if "musl", prefers a build of a linux toolchain linked
+	// against musl, as on Alpine, to the standard glibc one. Like GOARM
+	// variants, only flat mirrors can serve musl builds. HostLibc
+	// reports the C library of the host.
+	Libc string
+	// From is a local toolchain zip file to install instead of
+	// downloading one. It may also be a bundle written by WriteBundle,
+	// which is checked against its own manifest instead of the checksum
+	// database.
+	From string
+	// GOPROXY is the list of module proxies to download the toolchain
+	// module from, as in the go command's GOPROXY setting. If empty, a
+	// bootstrap toolchain is downloaded from the goup repository and
+	// switched to Version with go toolchain use.
+	GOPROXY string
+	// Mirror, if set, is the base URL of a server to download the
+	// toolchain module zip from instead of GOPROXY or the goup
+	// repository. MirrorLayout is the layout of its files, MirrorFlat
+	// if empty.
+	Mirror       string
+	MirrorLayout string
+	// Insecure skips verifying the downloaded toolchain against the
+	// Go checksum database.
+	Insecure bool
+	// SumDB is the checksum database to verify the toolchain against,
+	// in the syntax of the go command's GOSUMDB setting, e.g.
+	// "sum.golang.org" or "<key> https://sumdb.example.com". If empty,
+	// it is sum.golang.org. The value off skips verification, as
+	// Insecure does.
+	SumDB string
+	// GONOSUMDB is a comma-separated list of glob patterns of module
+	// path prefixes, as in the go command's GONOSUMDB setting. If one of
+	// them matches the toolchain module, the downloaded toolchain is not
+	// verified against the checksum database, as with Insecure.
+	GONOSUMDB string
+	// Checksum, if set, pins the expected hash of the toolchain zip,
+	// either as sha256:<hex> of the zip file or as the h1: module hash
+	// recorded in go.sum files. It is checked even if Insecure is set.
+	Checksum string
+	// SkipRun installs the toolchain without running its go command,
+	// which is otherwise run to check that it works, for machines that
+	// can only run it later, such as container images built without the
+	// dynamic loader. Its files are only checked against the checksum.
+	SkipRun bool
+	// Force reinstalls Version even if it is already installed.
+	Force bool
+	// Resume carries on with an extraction that was interrupted in an
+	// earlier run with Resume: files already extracted intact, according
+	// to their size and CRC-32 in the archive, are not written again.
+	// The partial extraction is kept if this one fails, too.
+	Resume bool
+	// CacheDir, if set, is a directory where downloaded toolchain zips
+	// are kept, so that installing the same toolchain again does not
+	// download it again.
+	CacheDir string
+	// KeepDir, if set, is a directory where the downloaded toolchain zip
+	// is kept after installation, named after its module version, e.g.
+	// v0.0.1-go1.22.3.linux-amd64.zip. It can be installed elsewhere
+	// with From.
+	KeepDir string
+	// Retries is the number of times a failed download is retried.
+	Retries int
+	// AttemptTimeout, if positive, bounds each attempt at a download,
+	// including reading the response, so that one stalled attempt does
+	// not use up the deadline of the context. An attempt that times out
+	// is retried, as long as the context allows.
+	AttemptTimeout time.Duration
+	// MaxRate, if positive, limits the download of the toolchain zip to
+	// that many bytes per second. The go command run for a bootstrap
+	// toolchain is not limited.
+	MaxRate int64
+	// RateLimiter, if set, limits the download of the toolchain zip
+	// instead of MaxRate. Plans sharing it, e.g. to install several
+	// versions concurrently, together stay within its rate.
+	RateLimiter *RateLimiter
+	// Limits bounds the size of the toolchain zip when extracted.
+	Limits ZipLimits
+	// Client is used for all HTTP requests, including those to the
+	// checksum database, and may route them anywhere with a custom
+	// Transport, e.g. to a test server or over a unix socket. If nil,
+	// http.DefaultClient is used. The go command run for a bootstrap
+	// toolchain (see GOPROXY) makes its own requests.
+	Client *http.Client
+	// Progress, if non-nil, is called as the toolchain is downloaded
+	// and extracted.
+	Progress ProgressFunc
+	// Output, if non-nil, receives the output of the go command run to
+	// switch a bootstrap toolchain to Version.
+	Output io.Writer
+}
+
+// Result describes a completed installation.
+type Result struct {
+	Version string
+	GOOS    string
+	GOARCH  string
+	Dir     string // the installed GOROOT
+	GoBin   string // the installed go command
+	// AlreadyInstalled reports that Version was already installed and
+	// nothing was done.
+	AlreadyInstalled bool
+	// Zip is where the toolchain zip was kept, with Options.KeepDir.
+	Zip string
+}
+
+// A Plan is an installation whose version, platform and download
+// source have been resolved, but that has not been carried out.
+type Plan struct {
+	Version string
+	GOOS    string
+	GOARCH  string
+	// GOARM is the ARM variant from Options.GOARM, or empty for the
+	// plain arm build, which is all most sources have.
+	GOARM string
+	// Libc is "musl" for a musl build of the toolchain, or empty for
+	// the standard build.
+	//
+	// GOARM and Libc describe the preferred build, the one of URLs[0].
+	// Source, and Run once it found the toolchain, set them to those of
+	// the build actually at hand, as sources may not have the preferred
+	// one.
+	Libc string
+	// Cross reports whether the toolchain is for another platform than
+	// the host. Such toolchains are installed but cannot be run.
+	Cross bool
+	// Dir is the directory the toolchain is installed in.
+	Dir string
+	// URLs are the candidate download URLs, in order of preference.
+	// It is empty when installing from Options.From.
+	URLs []string
+	// variants are the builds served at each of URLs.
+	variants []variant
+	// NoSumDB reports that the toolchain will not be verified against
+	// the checksum database, because of Options.Insecure, Options.SumDB
+	// or Options.GONOSUMDB.
+	NoSumDB bool
+
+	opts      Options
+	f         *fetcher
+	module    string // toolchain module version, e.g. v0.0.1-go1.22.3.linux-amd64
+	bootstrap bool
+	kept      string          // the zip saved by keepZip
+	bundle    *BundleManifest // of Options.From, if it is a bundle
+}
+
+// A variant is a build of a toolchain for a platform other than the
+// standard one, which is the zero variant (see Plan.GOARM and Plan.Libc).
+type variant struct {
+	goarm, libc string
+}
+
+// suffix returns what is appended to the module version of a toolchain
+// to name its variant v, as on a flat mirror (see MirrorFlat).
+func (v variant) suffix() string {
+	s := ""
+	if v.goarm != "" {
+		s += "v" + v.goarm
+	}
+	if v.libc != "" {
+		s += "-" + v.libc
+	}
+	return s
+}
+
+// candidates returns the variants that p may install, in order of
+// preference.
+func (p *Plan) candidates() []variant {
+	if len(p.variants) == 0 {
+		return []variant{{}}
+	}
+	return p.variants
+}
+
+// setVariant records that p installs v.
+func (p *Plan) setVariant(v variant, m *Manifest) {
+	p.GOARM, p.Libc = v.goarm, v.libc
+	if m != nil {
+		m.GOARM, m.Libc = v.goarm, v.libc
+	}
+}
+
+// urlVariant returns the variant served at u, one of p.URLs.
+func (p *Plan) urlVariant(u string) variant {
+	for i, pu := range p.URLs {
+		if pu == u && i < len(p.variants) {
+			return p.variants[i]
+		}
+	}
+	return variant{}
+}
+
+// Install installs the Go toolchain described by opts.
+func Install(ctx context.Context, opts Options) (Result, error) {
+	p, err := NewPlan(ctx, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return p.Run(ctx)
+}
+
+// NewPlan resolves opts into a Plan. It looks up the latest Go release
+// if opts.Version is empty, but does not otherwise download anything.
+func NewPlan(ctx context.Context, opts Options) (*Plan, error) {
+	version := opts.Version
+	if version != "" {
+		if err := ValidateVersion(version); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Checksum != "" {
+		if err := validateChecksum(opts.Checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	hostOS, hostArch, err := HostOSArch()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mirror != "" {
+		if err := validateMirror(opts.Mirror, opts.MirrorLayout); err != nil {
+			return nil, err
+		}
+	}
+	goos, goarch := hostOS, hostArch
+	if opts.GOOS != "" {
+		goos = opts.GOOS
+	}
+	if opts.GOARCH != "" {
+		goarch = opts.GOARCH
+	}
+	if err := ValidatePlatform(goos, goarch); err != nil {
+		return nil, err
+	}
+	if opts.GOARM != "" {
+		if err := validateGOARM(goarch, opts.GOARM); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &Plan{
+		GOOS:    goos,
+		GOARCH:  goarch,
+		Cross:   goos != hostOS || goarch != hostArch,
+		opts:    opts,
+		f:       newFetcher(opts.Client, opts.Retries, opts.Progress),
+		NoSumDB: opts.Insecure || module.MatchPrefixPatterns(opts.GONOSUMDB, gotoolchainModule),
+	}
+	p.f.maxRate = opts.MaxRate
+	p.f.limiter = opts.RateLimiter
+	p.f.attemptTimeout = opts.AttemptTimeout
+	if opts.SumDB == "off" {
+		p.NoSumDB = true
+	} else if p.f.sumdb, err = parseChecksumDB(opts.SumDB); err != nil {
+		return nil, err
+	}
+	p.f.cacheDir = opts.CacheDir
+
+	// When GOPROXY or a mirror is set, the toolchain module for the
+	// requested version is downloaded from there. Otherwise, a bootstrap
+	// toolchain is downloaded from the goup repository, and switched to
+	// the requested version with go toolchain use.
+	// With From, the toolchain is read from a local zip instead.
+	p.bootstrap = opts.GOPROXY == "" && opts.Mirror == ""
+	if opts.From != "" {
+		p.bundle, err = readBundle(opts.From)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b := p.bundle; b != nil {
+		// The bundle was verified when it was made, and its manifest
+		// is trusted instead of the checksum database.
+		if version != "" && version != b.Version {
+			return nil, fmt.Errorf("%v contains %v, not %v", opts.From, b.Version, version)
+		}
+		if b.GOOS != goos || b.GOARCH != goarch {
+			return nil, fmt.Errorf("%v contains Go for %v/%v, not %v/%v; set the platform to install", opts.From, b.GOOS, b.GOARCH, goos, goarch)
+		}
+		version, p.module = b.Version, b.Module
+		p.setVariant(variant{goarm: b.GOARM, libc: b.Libc}, nil)
+		p.bootstrap = false
+		p.NoSumDB = true
+	} else if opts.From != "" {
+		local, err := openLocalZip(opts.From)
+		if err != nil {
+			return nil, err
+		}
+		local.Close()
+		p.bootstrap = local.bootstrap()
+		if !p.bootstrap {
+			if version != "" && version != local.goVersion {
+				return nil, fmt.Errorf("%v contains %v, not %v", opts.From, local.goVersion, version)
+			}
+			version = local.goVersion
+		}
+		p.module = local.module
+	}
+	if version == Tip {
+		if opts.Mirror == "" || opts.MirrorLayout == MirrorProxy || opts.From != "" {
+			return nil, errors.New("gotip can only be installed from a flat mirror that serves tip builds; set one with -mirror or GOUP_MIRROR")
+		}
+		// Tip builds are not in the checksum database, and change with
+		// every commit, so caching them is of no use.
+		p.NoSumDB = true
+		p.opts.CacheDir = ""
+	}
+	if p.bootstrap && p.Cross {
+		return nil, fmt.Errorf("Go for %v/%v cannot be installed with the bootstrap toolchain; set GOPROXY or a mirror to install it", goos, goarch)
+	}
+	if p.bootstrap && opts.SkipRun {
+		return nil, errors.New("a bootstrap toolchain must run to switch to the requested version; set GOPROXY or a mirror to install without running the go command")
+	}
+
+	if version == "" {
+		v, err := p.f.latestVersion(ctx, opts.Unstable)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up the latest Go version: %w", err)
+		}
+		version = v
+	}
+	p.Version = version
+	// Each version is installed in its own directory under the root,
+	// so that installing a version does not overwrite another, nor the
+	// same version for another platform.
+	p.Dir = VersionDir(opts.Dir, version)
+	if p.Cross {
+		p.Dir = PlatformDir(opts.Dir, version, goos, goarch)
+	}
+
+	switch {
+	case p.module != "":
+		// A module zip from From.
+	case p.bootstrap:
+		p.module = toolchainArchiveName(bootstrapVersion, goos, goarch)
+	case version == Tip:
+		p.module = fmt.Sprintf("%v.%v-%v", Tip, goos, goarch)
+	default:
+		p.module = toolchainArchiveName(version, goos, goarch)
+	}
+	switch {
+	case opts.From != "":
+	case opts.Mirror != "":
+		// Fall back to the plain build if the mirror does not have
+		// the variant.
+		add := func(v variant) {
+			p.URLs = append(p.URLs, mirrorZipURL(opts.Mirror, opts.MirrorLayout, p.module+v.suffix()))
+			p.variants = append(p.variants, v)
+		}
+		var goarm string
+		if opts.GOARM != "" && opts.MirrorLayout != MirrorProxy {
+			goarm = opts.GOARM
+		}
+		if opts.Libc == "musl" && goos == "linux" && opts.MirrorLayout != MirrorProxy {
+			add(variant{goarm, "musl"})
+		}
+		if goarm != "" {
+			add(variant{goarm, ""})
+		}
+		add(variant{})
+		p.setVariant(p.variants[0], nil)
+	case p.bootstrap:
+		p.URLs = []string{fmt.Sprintf("https://github.com/hyangah/goup/raw/main/res/%v.zip", p.module)}
+	default:
+		p.URLs = proxyZipURLs(opts.GOPROXY, p.module)
+	}
+	return p, nil
+}
+
+// Source returns where the toolchain would be downloaded from: the
+// first of p.URLs that answers a HEAD request, or Options.From.
+// It downloads nothing.
+func (p *Plan) Source(ctx context.Context) (string, error) {
+	if p.opts.From != "" {
+		return p.opts.From, nil
+	}
+	err := errors.New("no download source")
+	for _, u := range p.URLs {
+		var r *http.Response
+		r, err = p.f.doRequest(ctx, "HEAD", u)
+		if err == nil {
+			closeBody(r.Body)
+			p.setVariant(p.urlVariant(u), nil)
+			return u, nil
+		}
+	}
+	return "", err
+}
+
+// Downloads reports whether Run would download the toolchain, rather
+// than install it from Options.From or the cache.
+func (p *Plan) Downloads() bool {
+	return p.opts.From == "" && !p.isCached()
+}
+
+// FromBundle reports whether Options.From is a bundle.
+func (p *Plan) FromBundle() bool {
+	return p.bundle != nil
+}
+
+// Installed reports whether p.Version is already installed in p.Dir.
+// Unless p.Cross or Options.SkipRun is set, the installed go command
+// must run and report the version; toolchains for other platforms are
+// only checked for their VERSION file. A toolchain whose manifest
+// records another platform is not the one planned.
+func (p *Plan) Installed() bool {
+	if m, err := ReadManifest(p.Dir); err == nil && m.GOOS != "" && (m.GOOS != p.GOOS || m.GOARCH != p.GOARCH) {
+		return false
+	}
+	if !p.Cross && !p.opts.SkipRun {
+		got, err := GoVersion(GoBinary(p.Dir))
+		return err == nil && versionMatches(got, p.Version)
+	}
+	data, err := os.ReadFile(filepath.Join(p.Dir, "VERSION"))
+	if err != nil {
+		return false
+	}
+	got, _, _ := strings.Cut(string(data), "\n")
+	return versionMatches(got, p.Version)
+}
+
+// Run carries out the installation.
+func (p *Plan) Run(ctx context.Context) (Result, error) {
+	gobin := GoBinary(p.Dir)
+	result := Result{Version: p.Version, GOOS: p.GOOS, GOARCH: p.GOARCH, Dir: p.Dir, GoBin: gobin}
+	if !p.opts.Force && p.Installed() {
+		result.AlreadyInstalled = true
+		return result, nil
+	}
+	if _, err := os.Stat(p.Dir); err == nil && !IsManaged(p.Dir) {
+		return Result{}, fmt.Errorf("%v exists and was not installed by goup; refusing to overwrite it", p.Dir)
+	}
+	// Fail early, rather than after a long download, if the toolchain
+	// cannot be written.
+	if err := checkWritable(filepath.Dir(p.Dir)); err != nil {
+		return Result{}, err
+	}
+
+	m := Manifest{Version: p.Version, GOOS: p.GOOS, GOARCH: p.GOARCH}
+	var err error
+	switch {
+	case p.opts.From != "":
+		err = p.installLocal(ctx, &m)
+	case p.isCached():
+		err = p.installCached(ctx, &m)
+	default:
+		err = p.fetch(ctx, &m)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	result.Zip = p.kept
+	if p.Cross {
+		return result, nil
+	}
+	if p.bootstrap {
+		if err := p.toolchainUse(gobin); err != nil {
+			return Result{}, err
+		}
+	}
+	if p.opts.SkipRun {
+		return result, nil
+	}
+	// Make sure the installed go command runs and is the version we
+	// meant to install.
+	if err := checkRuns(gobin, p.GOOS, p.GOARCH, p.Version); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// toolchainUse switches the bootstrap toolchain gobin to p.Version.
+func (p *Plan) toolchainUse(gobin string) error {
+	var stderr bytes.Buffer
+	c := exec.Command(gobin, "toolchain", "use", p.Version)
+	c.Stdout = p.opts.Output
+	c.Stderr = &stderr
+	if p.opts.Output != nil {
+		c.Stderr = io.MultiWriter(p.opts.Output, &stderr)
+	}
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%v toolchain use %v: %v\n%s", gobin, p.Version, err, stderr.Bytes())
+	}
+	return nil
+}
+
+// checkWritable reports an error if files cannot be created in dir,
+// which is created if it does not exist.
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("cannot write to %v: %v", dir, errors.Unwrap(err))
+	}
+	f, err := os.CreateTemp(dir, ".goup-write-test-")
+	if err != nil {
+		return fmt.Errorf("cannot write to %v: %v", dir, errors.Unwrap(err))
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// installLocal installs the toolchain zip or bundle of Options.From.
+func (p *Plan) installLocal(ctx context.Context, m *Manifest) error {
+	file := p.opts.From
+	if p.bundle != nil {
+		var err error
+		if file, err = extractBundle(p.opts.From, filepath.Dir(p.Dir), p.bundle); err != nil {
+			return err
+		}
+		defer os.Remove(file)
+		p.setVariant(variant{goarm: p.bundle.GOARM, libc: p.bundle.Libc}, m)
+	}
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	from, _ := filepath.Abs(p.opts.From)
+	return p.installZip(ctx, &z.Reader, file, "", "file://"+filepath.ToSlash(from), m)
+}
+
+// fetch downloads and installs the toolchain from its source.
+func (p *Plan) fetch(ctx context.Context, m *Manifest) error {
+	switch {
+	case p.bootstrap:
+		return p.download(ctx, p.URLs[0], m)
+	case p.opts.Mirror != "":
+		var err error
+		for _, u := range p.URLs {
+			err = p.download(ctx, u, m)
+			if !isNotFound(err) {
+				break
+			}
+		}
+		return err
+	}
+	return p.downloadFromProxies(ctx, m)
+}
+
+// download fetches the toolchain module zip from uri and installs it
+// with installZip. The zip is then kept in the cache, if any.
+func (p *Plan) download(ctx context.Context, uri string, m *Manifest) error {
+	z, err := p.f.downloadZip(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	v := p.urlVariant(uri)
+	p.setVariant(v, m)
+	if err := p.installZip(ctx, &z.Reader, z.path, z.sha256, uri, m); err != nil {
+		return err
+	}
+	if p.opts.CacheDir != "" {
+		// The toolchain is installed; failing to cache it is not fatal.
+		p.saveToCache(z.path, m.Checksum, v)
+	}
+	if p.opts.KeepDir != "" {
+		return p.keepZip(z.path)
+	}
+	return nil
+}
+
+// installZip verifies r, the toolchain module zip stored in file and
+// obtained from uri, and extracts it into p.Dir. zipSum is the SHA-256
+// of file in hex if it was computed during the download, or empty.
+// The remaining fields of m are filled in, and it is written as the
+// manifest of the installation.
+//
+// The archive is extracted into a temporary sibling of p.Dir that is
+// renamed to p.Dir only once extraction succeeds, so that a failed
+// install never leaves a partial toolchain behind.
+func (p *Plan) installZip(ctx context.Context, r *zip.Reader, file, zipSum, uri string, m *Manifest) (err error) {
+	dst, ver := p.Dir, p.module
+	prefix := toolchainPrefix(r, gotoolchainModule+"@"+ver+"/")
+	if err := checkToolchainZip(r, prefix); err != nil {
+		return fmt.Errorf("%v: %v", uri, err)
+	}
+	sum, err := hashZip(r, gotoolchainModule, ver, prefix)
+	if err != nil {
+		return fmt.Errorf("hashing downloaded toolchain: %v", err)
+	}
+	if p.opts.Checksum != "" {
+		if err := checkPinnedChecksum(p.opts.Checksum, sum, zipSum, file); err != nil {
+			return err
+		}
+	}
+	if p.bundle != nil {
+		if err := checkPinnedChecksum(p.bundle.Checksum, sum, zipSum, file); err != nil {
+			return fmt.Errorf("%v: %v", p.opts.From, err)
+		}
+	}
+	if !p.NoSumDB {
+		if err := p.f.verifyChecksum(ctx, sum, gotoolchainModule, ver); err != nil {
+			return fmt.Errorf("verifying downloaded toolchain: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	var tmp string
+	if p.opts.Resume {
+		// Extract into a directory with a fixed name, which is kept if
+		// the extraction fails, so that the next run can carry on.
+		tmp = filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".partial")
+		err = os.MkdirAll(tmp, os.ModePerm)
+	} else {
+		tmp, err = os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-")
+		defer func() {
+			if err != nil {
+				os.RemoveAll(tmp)
+			}
+		}()
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkDiskSpace(tmp, r); err != nil {
+		return err
+	}
+	if err := writeZip(ctx, tmp, prefix, r, p.opts.Limits, p.opts.Progress, p.opts.Resume); err != nil {
+		return err
+	}
+	// Module zips from a proxy do not record file modes.
+	if err := SetExecutable(ver, tmp); err != nil {
+		return err
+	}
+	m.Module = ver
+	m.URL = uri
+	m.Checksum = sum
+	if p.Version == Tip {
+		m.Commit = tipCommit(tmp)
+	}
+	m.InstalledAt = time.Now().UTC()
+	if err := writeManifest(tmp, m); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		// Replace the existing installation (see Options.Force). Move it
+		// aside first, and put it back if the new one cannot be moved in.
+		old := tmp + ".old"
+		if err := os.Rename(dst, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			os.Rename(old, dst)
+			return err
+		}
+		os.RemoveAll(old)
+	} else if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return nil
+}
+
+// HostOSArch returns the GOOS and GOARCH of the machine the program runs
+// on. On an arm64 Mac, it reports arm64 even if the program is an amd64
+// binary running under Rosetta.
+func HostOSArch() (host, arch string, _ error) {
+	host, arch = runtime.GOOS, runtime.GOARCH
+	if host == "darwin" && arch == "amd64" && processIsTranslated() {
+		arch = "arm64"
+	}
+	return host, arch, nil
+}
+
+// VersionDir returns the directory the given Go version is installed in
+// under root.
+func VersionDir(root, version string) string {
+	return filepath.Join(root, version)
+}
+
+// PlatformDir returns the directory the given Go version for goos/goarch,
+// another platform than the host, is installed in under root, such as
+// <root>/go1.22.3.windows-amd64.
+func PlatformDir(root, version, goos, goarch string) string {
+	return filepath.Join(root, version+"."+goos+"-"+goarch)
+}
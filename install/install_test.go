@@ -0,0 +1,314 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestToolchainArchiveName(t *testing.T) {
+	for _, tc := range []struct {
+		version, goos, goarch string
+		want                  string
+	}{
+		{"go1.22.3", "linux", "amd64", "v0.0.1-go1.22.3.linux-amd64"},
+		{"go1.21.0", "windows", "arm64", "v0.0.1-go1.21.0.windows-arm64"},
+		{"go1.23rc1", "darwin", "arm64", "v0.0.1-go1.23rc1.darwin-arm64"},
+		{"go1.22beta1", "linux", "386", "v0.0.1-go1.22beta1.linux-386"},
+		// Patch-less versions are not normalized.
+		{"go1.21", "linux", "amd64", "v0.0.1-go1.21.linux-amd64"},
+		{bootstrapVersion, "darwin", "amd64", "v0.0.1-go1.21.0beta1-installer.darwin-amd64"},
+	} {
+		if got := toolchainArchiveName(tc.version, tc.goos, tc.goarch); got != tc.want {
+			t.Errorf("toolchainArchiveName(%q, %q, %q) = %q, want %q", tc.version, tc.goos, tc.goarch, got, tc.want)
+		}
+	}
+}
+
+func TestNewPlanURLs(t *testing.T) {
+	const mirror = "https://mirror.example.com/go"
+	for _, tc := range []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "release",
+			opts: Options{Version: "go1.22.3", GOOS: "linux", GOARCH: "amd64"},
+			want: []string{mirror + "/v0.0.1-go1.22.3.linux-amd64.zip"},
+		},
+		{
+			name: "release candidate",
+			opts: Options{Version: "go1.23rc1", GOOS: "darwin", GOARCH: "arm64"},
+			want: []string{mirror + "/v0.0.1-go1.23rc1.darwin-arm64.zip"},
+		},
+		{
+			name: "tip",
+			opts: Options{Version: Tip, GOOS: "linux", GOARCH: "amd64"},
+			want: []string{mirror + "/gotip.linux-amd64.zip"},
+		},
+		{
+			name: "GOARM",
+			opts: Options{Version: "go1.22.3", GOOS: "linux", GOARCH: "arm", GOARM: "7"},
+			want: []string{
+				mirror + "/v0.0.1-go1.22.3.linux-armv7.zip",
+				mirror + "/v0.0.1-go1.22.3.linux-arm.zip",
+			},
+		},
+		{
+			name: "musl",
+			opts: Options{Version: "go1.22.3", GOOS: "linux", GOARCH: "amd64", Libc: "musl"},
+			want: []string{
+				mirror + "/v0.0.1-go1.22.3.linux-amd64-musl.zip",
+				mirror + "/v0.0.1-go1.22.3.linux-amd64.zip",
+			},
+		},
+		{
+			name: "GOARM and musl",
+			opts: Options{Version: "go1.22.3", GOOS: "linux", GOARCH: "arm", GOARM: "6", Libc: "musl"},
+			want: []string{
+				mirror + "/v0.0.1-go1.22.3.linux-armv6-musl.zip",
+				mirror + "/v0.0.1-go1.22.3.linux-armv6.zip",
+				mirror + "/v0.0.1-go1.22.3.linux-arm.zip",
+			},
+		},
+		{
+			name: "musl is linux only",
+			opts: Options{Version: "go1.22.3", GOOS: "windows", GOARCH: "amd64", Libc: "musl"},
+			want: []string{mirror + "/v0.0.1-go1.22.3.windows-amd64.zip"},
+		},
+		{
+			name: "proxy layout has no variants",
+			opts: Options{Version: "go1.22.3", GOOS: "linux", GOARCH: "arm", GOARM: "7", Libc: "musl", MirrorLayout: MirrorProxy},
+			want: []string{mirror + "/golang.org/toolchain/@v/v0.0.1-go1.22.3.linux-arm.zip"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.Dir = t.TempDir()
+			opts.Mirror = mirror
+			p, err := NewPlan(context.Background(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p.URLs, tc.want) {
+				t.Errorf("URLs = %q, want %q", p.URLs, tc.want)
+			}
+		})
+	}
+}
+
+// flatMirror returns a flat mirror (see MirrorFlat) serving only the
+// zips of files, keyed by module version, and a function counting their
+// downloads.
+func flatMirror(t *testing.T, files map[string][]byte) (*httptest.Server, func(name string) int) {
+	var (
+		mu    sync.Mutex
+		count = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".zip")
+		data, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			mu.Lock()
+			count[name]++
+			mu.Unlock()
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return count[name]
+	}
+}
+
+// fakeToolchainZip returns a toolchain zip for version, whose go command
+// does not run.
+func fakeToolchainZip(t *testing.T, version string) []byte {
+	return zipData(t,
+		zipEntry{name: "go/VERSION", body: version + "\n"},
+		zipEntry{name: "go/bin/go", mode: 0o755},
+	)
+}
+
+func TestRunVariantFallback(t *testing.T) {
+	// The mirror has only the plain arm build, not the GOARM=7 one.
+	const plain = "v0.0.1-go1.22.3.linux-arm"
+	srv, downloads := flatMirror(t, map[string][]byte{plain: fakeToolchainZip(t, "go1.22.3")})
+	root := t.TempDir()
+	opts := Options{
+		Version:  "go1.22.3",
+		Dir:      root,
+		GOOS:     "linux",
+		GOARCH:   "arm",
+		GOARM:    "7",
+		Mirror:   srv.URL,
+		Insecure: true,
+		SkipRun:  true,
+		CacheDir: filepath.Join(root, "cache"),
+		Client:   srv.Client(),
+	}
+	p, err := NewPlan(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GOARM != "7" {
+		t.Errorf("before Run, GOARM = %q, want the preferred 7", p.GOARM)
+	}
+	res, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GOARM != "" {
+		t.Errorf("after Run, GOARM = %q, want \"\" for the plain build", p.GOARM)
+	}
+	if m, err := ReadManifest(res.Dir); err != nil || m.GOARM != "" {
+		t.Errorf("manifest = %+v, %v; want no GOARM", m, err)
+	}
+	// The plain build is cached under its own name, and used from there.
+	cacheDir := filepath.Join(opts.CacheDir, "golang.org", "toolchain", "@v")
+	if _, err := os.Stat(filepath.Join(cacheDir, plain+".zip")); err != nil {
+		t.Errorf("plain build not cached: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, plain+"v7.zip")); err == nil {
+		t.Errorf("plain build cached as the GOARM=7 variant")
+	}
+	opts.Force = true
+	p, err = NewPlan(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.isCached() {
+		t.Errorf("plain build not found in the cache")
+	}
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := downloads(plain); n != 1 || p.GOARM != "" {
+		t.Errorf("after reinstalling, downloads = %d, GOARM = %q; want 1 and \"\"", n, p.GOARM)
+	}
+}
+
+func TestRunMusl(t *testing.T) {
+	host, arch, _ := HostOSArch()
+	if host != "linux" {
+		t.Skip("musl builds are for linux")
+	}
+	const (
+		plain = "v0.0.1-go1.22.3.linux-"
+		musl  = "-musl"
+	)
+	for _, tc := range []struct {
+		name     string
+		served   string // the module version the mirror has
+		wantLibc string
+	}{
+		{"musl build served", plain + arch + musl, "musl"},
+		{"plain build only", plain + arch, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, _ := flatMirror(t, map[string][]byte{tc.served: fakeToolchainZip(t, "go1.22.3")})
+			root := t.TempDir()
+			p, err := NewPlan(context.Background(), Options{
+				Version:  "go1.22.3",
+				Dir:      root,
+				Libc:     "musl",
+				Mirror:   srv.URL,
+				Insecure: true,
+				SkipRun:  true,
+				CacheDir: filepath.Join(root, "cache"),
+				Client:   srv.Client(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := p.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Libc != tc.wantLibc {
+				t.Errorf("Libc = %q, want %q", p.Libc, tc.wantLibc)
+			}
+			if m, err := ReadManifest(res.Dir); err != nil || m.Libc != tc.wantLibc {
+				t.Errorf("manifest = %+v, %v; want Libc %q", m, err, tc.wantLibc)
+			}
+			// Only the build that was downloaded is in the cache.
+			entries, _ := filepath.Glob(filepath.Join(root, "cache", "golang.org", "toolchain", "@v", "*.zip"))
+			if len(entries) != 1 || filepath.Base(entries[0]) != tc.served+".zip" {
+				t.Errorf("cached %q, want only %s.zip", entries, tc.served)
+			}
+		})
+	}
+}
+
+func TestBundleVariant(t *testing.T) {
+	const armv7 = "v0.0.1-go1.22.3.linux-armv7"
+	srv, _ := flatMirror(t, map[string][]byte{armv7: fakeToolchainZip(t, "go1.22.3")})
+	root := t.TempDir()
+	p, err := NewPlan(context.Background(), Options{
+		Version:  "go1.22.3",
+		Dir:      filepath.Join(root, "made"),
+		GOOS:     "linux",
+		GOARCH:   "arm",
+		GOARM:    "7",
+		Mirror:   srv.URL,
+		Insecure: true,
+		SkipRun:  true,
+		KeepDir:  filepath.Join(root, "keep"),
+		Client:   srv.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(res.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(root, "bundle.zip")
+	if err := WriteBundle(bundle, res.Zip, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// Installed from the bundle, the toolchain is still the GOARM=7
+	// variant.
+	p, err = NewPlan(context.Background(), Options{
+		Dir:     filepath.Join(root, "installed"),
+		GOOS:    "linux",
+		GOARCH:  "arm",
+		From:    bundle,
+		SkipRun: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GOARM != "7" {
+		t.Errorf("GOARM of a plan for the bundle = %q, want 7", p.GOARM)
+	}
+	res, err = p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := ReadManifest(res.Dir); err != nil || m.GOARM != "7" {
+		t.Errorf("manifest installed from the bundle = %+v, %v; want GOARM 7", m, err)
+	}
+}
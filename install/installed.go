@@ -0,0 +1,196 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Installation is a Go toolchain installed under a root directory.
+type Installation struct {
+	Version string // Go version, e.g. go1.22.3, Tip or "unknown"
+	Commit  string // of a Tip build, if known
+	Dir     string // GOROOT of the toolchain
+	Active  bool   // whether it is the toolchain ActiveVersion returns
+	Default bool   // whether it is the default version
+	// GOOS and GOARCH are set for a toolchain for another platform than
+	// the host (see PlatformDir), which cannot run here.
+	GOOS, GOARCH string
+}
+
+// InstalledVersions returns the Go toolchains installed in the immediate
+// subdirectories of root, in directory order. Entries that do not hold a
+// go command, such as <root>/bin or symlinks to removed directories, are
+// skipped. Toolchains for other platforms are included, with their GOOS
+// and GOARCH set. It returns no error if root does not exist.
+func InstalledVersions(root string) ([]Installation, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	active, err := ActiveVersion(root)
+	if err != nil {
+		return nil, err
+	}
+	dflt, err := defaultDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var installs []Installation
+	for _, e := range entries {
+		dir := filepath.Join(root, e.Name())
+		// os.Stat follows symlinks to toolchains installed elsewhere.
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		gobin := GoBinary(dir)
+		if _, err := os.Stat(gobin); err != nil {
+			continue
+		}
+		in := installation(dir)
+		if m, err := ReadManifest(dir); err == nil && m.GOOS != "" && !isHost(m.GOOS, m.GOARCH) {
+			in.GOOS, in.GOARCH = m.GOOS, m.GOARCH
+		}
+		in.Active = dir == active.Dir
+		in.Default = dir == dflt
+		installs = append(installs, in)
+	}
+	return installs, nil
+}
+
+// isHost reports whether goos/goarch is the host platform.
+func isHost(goos, goarch string) bool {
+	host, arch, err := HostOSArch()
+	return err == nil && goos == host && goarch == arch
+}
+
+// installation describes the toolchain in dir, preferring the version
+// recorded at install time over running its go command.
+func installation(dir string) Installation {
+	if m, err := ReadManifest(dir); err == nil && m.Version != "" {
+		return Installation{Version: m.Version, Commit: m.Commit, Dir: dir}
+	}
+	version, err := GoVersion(GoBinary(dir))
+	if err != nil {
+		version = "unknown"
+	}
+	return Installation{Version: version, Dir: dir}
+}
+
+// ActiveVersion returns the active toolchain under root: the one that
+// <root>/bin/go runs, as selected by goup use, or, if there is none or it
+// was removed since, the default version. Its Dir is "" if neither is
+// installed.
+func ActiveVersion(root string) (Installation, error) {
+	dir, err := SelectedDir(root)
+	if err != nil {
+		return Installation{}, err
+	}
+	if dir != "" {
+		if _, err := os.Stat(GoBinary(dir)); err != nil {
+			dir = ""
+		}
+	}
+	if dir == "" {
+		if dir, err = defaultDir(root); err != nil || dir == "" {
+			return Installation{}, err
+		}
+	}
+	in := installation(dir)
+	in.Active = true
+	if dflt, err := defaultDir(root); err == nil {
+		in.Default = dir == dflt
+	}
+	return in, nil
+}
+
+// SelectedDir returns the directory of the toolchain that <root>/bin/go
+// points at, or "" if no version was selected with goup use. On Windows,
+// <root>/bin/go.cmd is a batch file that runs the selected go command.
+func SelectedDir(root string) (string, error) {
+	var target string
+	if runtime.GOOS == "windows" {
+		data, err := os.ReadFile(filepath.Join(root, "bin", "go.cmd"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		// The shim looks like @"<dir>\bin\go.exe" %*.
+		s := strings.TrimPrefix(string(data), "@\"")
+		s, _, _ = strings.Cut(s, "\"")
+		target = strings.TrimSuffix(s, ".exe")
+	} else {
+		t, err := os.Readlink(filepath.Join(root, "bin", "go"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		target = t
+	}
+	// target is <dir>/bin/go.
+	return filepath.Dir(filepath.Dir(target)), nil
+}
+
+// defaultFile returns the file in root that records the default version.
+func defaultFile(root string) string {
+	return filepath.Join(root, "default")
+}
+
+// DefaultVersion returns the default version recorded in root, or "" if
+// none was recorded.
+func DefaultVersion(root string) (string, error) {
+	data, err := os.ReadFile(defaultFile(root))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(data))
+	if err := ValidateVersion(version); err != nil {
+		return "", fmt.Errorf("%v: %v", defaultFile(root), err)
+	}
+	return version, nil
+}
+
+// SetDefaultVersion records version as the default version in root. An
+// empty version forgets the default.
+func SetDefaultVersion(root, version string) error {
+	if version == "" {
+		err := os.Remove(defaultFile(root))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := ValidateVersion(version); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(defaultFile(root), []byte(version+"\n"), 0o644)
+}
+
+// defaultDir returns the directory of the default version, or "" if
+// there is none or it is not installed.
+func defaultDir(root string) (string, error) {
+	version, err := DefaultVersion(root)
+	if err != nil || version == "" {
+		return "", err
+	}
+	dir := VersionDir(root, version)
+	if _, err := os.Stat(GoBinary(dir)); err != nil {
+		return "", nil
+	}
+	return dir, nil
+}
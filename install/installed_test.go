@@ -0,0 +1,147 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeToolchain writes a toolchain with a manifest for version into
+// root/name, whose go command does not run: the manifest tells its
+// version.
+func fakeToolchain(t *testing.T, root, name, version string) string {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GoBinary(dir), nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	host, arch, _ := HostOSArch()
+	if err := writeManifest(dir, &Manifest{Version: version, GOOS: host, GOARCH: arch}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// mustWrite writes data to root/name, creating its directory.
+func mustWrite(t *testing.T, root, name, data string) {
+	t.Helper()
+	file := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstalledVersionsMessy(t *testing.T) {
+	root := t.TempDir()
+	go121 := fakeToolchain(t, root, "go1.21.0", "go1.21.0")
+	go122 := fakeToolchain(t, root, "go1.22.3", "go1.22.3")
+	// A toolchain for another platform is listed with its platform.
+	cross := fakeToolchain(t, root, "go1.22.3.plan9-386", "go1.22.3")
+	if err := writeManifest(cross, &Manifest{Version: "go1.22.3", GOOS: "plan9", GOARCH: "386"}); err != nil {
+		t.Fatal(err)
+	}
+	// Stray files and directories that hold no go command.
+	mustWrite(t, root, "default", "go1.21.0\n")
+	mustWrite(t, root, "notes.txt", "hello")
+	mustWrite(t, root, "cache/releases.json", "{}")
+	mustWrite(t, root, "go1.20.1/VERSION", "go1.20.1") // removed halfway
+	mustWrite(t, root, "go1.21.0beta1-installer/README", "bootstrap")
+	if runtime.GOOS != "windows" {
+		// A link to a removed toolchain, and one whose go is dangling.
+		if err := os.Symlink(filepath.Join(root, "gone"), filepath.Join(root, "go1.19")); err != nil {
+			t.Fatal(err)
+		}
+		dangling := filepath.Join(root, "go1.18", "bin")
+		if err := os.MkdirAll(dangling, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(root, "gone", "go"), filepath.Join(dangling, "go")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	installs, err := InstalledVersions(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Installation{
+		{Version: "go1.21.0", Dir: go121, Active: true, Default: true},
+		{Version: "go1.22.3", Dir: go122},
+		{Version: "go1.22.3", Dir: cross, GOOS: "plan9", GOARCH: "386"},
+	}
+	if len(installs) != len(want) {
+		t.Fatalf("InstalledVersions = %+v, want %+v", installs, want)
+	}
+	for i := range want {
+		if installs[i] != want[i] {
+			t.Errorf("InstalledVersions[%d] = %+v, want %+v", i, installs[i], want[i])
+		}
+	}
+}
+
+func TestInstalledVersionsNoRoot(t *testing.T) {
+	installs, err := InstalledVersions(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(installs) != 0 {
+		t.Errorf("InstalledVersions of a missing root = %v, %v; want none", installs, err)
+	}
+}
+
+func TestActiveVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the active version is selected with a batch file on Windows")
+	}
+	root := t.TempDir()
+	go121 := fakeToolchain(t, root, "go1.21.0", "go1.21.0")
+	go122 := fakeToolchain(t, root, "go1.22.3", "go1.22.3")
+	link := filepath.Join(root, "bin", "go")
+
+	// Nothing selected and no default.
+	if in, err := ActiveVersion(root); err != nil || in.Dir != "" {
+		t.Errorf("ActiveVersion with nothing selected = %+v, %v; want none", in, err)
+	}
+
+	// The default is active until another version is selected.
+	if err := SetDefaultVersion(root, "go1.21.0"); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := ActiveVersion(root); err != nil || in.Dir != go121 || !in.Default {
+		t.Errorf("ActiveVersion with a default = %+v, %v; want the default %s", in, err, go121)
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(GoBinary(go122), link); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := ActiveVersion(root); err != nil || in.Dir != go122 || in.Version != "go1.22.3" || in.Default {
+		t.Errorf("ActiveVersion with go1.22.3 selected = %+v, %v; want %s", in, err, go122)
+	}
+
+	// With the selected version removed, the link dangles and the
+	// default is active again.
+	if err := os.RemoveAll(go122); err != nil {
+		t.Fatal(err)
+	}
+	if in, err := ActiveVersion(root); err != nil || in.Dir != go121 {
+		t.Errorf("ActiveVersion with a dangling link = %+v, %v; want the default %s", in, err, go121)
+	}
+}
+
+func TestDefaultVersionInvalid(t *testing.T) {
+	root := t.TempDir()
+	mustWrite(t, root, "default", "not a version\n")
+	if _, err := DefaultVersion(root); err == nil {
+		t.Errorf("DefaultVersion succeeded with an invalid default file")
+	}
+}
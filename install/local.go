@@ -0,0 +1,66 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// localZip is a toolchain zip read from the local file system (see -from).
+type localZip struct {
+	*zip.ReadCloser
+	// goVersion is the content of the VERSION file,
+	// e.g. go1.22.3 or go1.21.0beta1-installer.
+	goVersion string
+	// module is the toolchain module version, if the zip is a module zip
+	// with files under a golang.org/toolchain@<module>/ directory.
+	module string
+}
+
+// openLocalZip opens the toolchain zip at file.
+func openLocalZip(file string) (*localZip, error) {
+	rc, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	z := &localZip{ReadCloser: rc}
+	for _, f := range rc.File {
+		name := f.Name
+		if rest, ok := strings.CutPrefix(name, gotoolchainModule+"@"); ok {
+			mod, n, _ := strings.Cut(rest, "/")
+			z.module, name = mod, n
+		}
+		if name != "VERSION" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		z.goVersion, _, _ = strings.Cut(string(data), "\n")
+	}
+	if z.goVersion == "" {
+		rc.Close()
+		return nil, fmt.Errorf("%s: not a Go toolchain zip: %s not found", file, path.Join(z.module, "VERSION"))
+	}
+	return z, nil
+}
+
+// bootstrap reports whether z holds the bootstrap toolchain, which is
+// switched to the requested version with go toolchain use.
+func (z *localZip) bootstrap() bool {
+	return strings.HasSuffix(z.goVersion, installerSuffix)
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// manifestFile is written into every directory goup installs a toolchain
+// into. Besides recording how the toolchain was installed, it marks the
+// directory as managed by goup, so that goup never removes or overwrites
+// a directory it did not create.
+const manifestFile = ".goup.json"
+
+// Manifest is the content of the manifest file goup writes into every
+// installed toolchain.
+type Manifest struct {
+	Version     string    `json:"version"`          // Go version, e.g. go1.22.3
+	Module      string    `json:"module"`           // toolchain module version, e.g. v0.0.1-go1.22.3.linux-amd64
+	URL         string    `json:"url"`              // where the module zip was downloaded from
+	Checksum    string    `json:"checksum"`         // h1: hash of the module zip
+	Commit      string    `json:"commit,omitempty"` // of a Tip build, e.g. 3a8b2f1
+	GOOS        string    `json:"goos"`
+	GOARCH      string    `json:"goarch"`
+	GOARM       string    `json:"goarm,omitempty"` // of an arm toolchain variant, see Plan.GOARM
+	Libc        string    `json:"libc,omitempty"`  // "musl" for a musl build, see Plan.Libc
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// ReadManifest reads the manifest of the toolchain installed in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeManifest writes m as the manifest of the toolchain installed in dir.
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0o644)
+}
+
+// IsManaged reports whether dir holds a toolchain installed by goup.
+func IsManaged(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, manifestFile))
+	return err == nil
+}
+
+// hashInstalled returns the h1: hash of the files installed in dir,
+// computed as for the module zip of mod@version it was extracted from,
+// so that it can be compared with the checksum in the manifest.
+func hashInstalled(dir, mod, version string) (string, error) {
+	prefix := mod + "@" + version + "/"
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel != manifestFile {
+			names = append(names, prefix+filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		file := filepath.Join(dir, filepath.FromSlash(name[len(prefix):]))
+		fi, err := os.Lstat(file)
+		if err != nil {
+			return nil, err
+		}
+		// A symlink is hashed as its target, which is what the zip
+		// holds, rather than as the file it points to.
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(file)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(strings.NewReader(filepath.ToSlash(target))), nil
+		}
+		return os.Open(file)
+	})
+}
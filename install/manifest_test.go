@@ -0,0 +1,72 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"io/fs"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if IsManaged(dir) {
+		t.Fatalf("IsManaged(%s) = true before writing a manifest", dir)
+	}
+	want := &Manifest{
+		Version:     "go1.22.3",
+		Module:      "v0.0.1-go1.22.3.linux-amd64",
+		URL:         "https://proxy.golang.org/golang.org/toolchain/@v/v0.0.1-go1.22.3.linux-amd64.zip",
+		Checksum:    "h1:abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG=",
+		GOOS:        "linux",
+		GOARCH:      "amd64",
+		InstalledAt: time.Date(2024, 5, 7, 16, 3, 21, 0, time.UTC),
+	}
+	if err := writeManifest(dir, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadManifest = %+v, want %+v", got, want)
+	}
+	if !IsManaged(dir) {
+		t.Errorf("IsManaged(%s) = false after writing a manifest", dir)
+	}
+}
+
+func TestReadManifestMissing(t *testing.T) {
+	if _, err := ReadManifest(t.TempDir()); err == nil {
+		t.Errorf("ReadManifest succeeded without a manifest")
+	}
+}
+
+func TestHashInstalledSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	const mod, version = gotoolchainModule, "v0.0.1-go1.22.3.linux-amd64"
+	archive := makeZip(t,
+		zipEntry{name: "go/VERSION", body: "go1.22.3"},
+		zipEntry{name: "go/lib/link", body: "../src/file", mode: fs.ModeSymlink | 0o777},
+		zipEntry{name: "go/src/file", body: "hello"},
+	)
+	want, err := hashZip(archive, mod, version, "go/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := WriteZip(context.Background(), dir, "go/", archive, ZipLimits{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := hashInstalled(dir, mod, version); err != nil || got != want {
+		t.Errorf("hashInstalled = %v, %v; want %v, the hash of the zip", got, err, want)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Layouts of a mirror, for Options.MirrorLayout.
+const (
+	// MirrorFlat is a mirror serving the toolchain module zips under
+	// their module version, as in <mirror>/v0.0.1-go1.22.3.linux-amd64.zip.
+	// It may also serve GOARM variants of arm toolchains, with the
+	// variant appended, as in v0.0.1-go1.22.3.linux-armv7.zip, and
+	// builds of linux toolchains linked against musl, with -musl
+	// appended, as in v0.0.1-go1.22.3.linux-amd64-musl.zip, and the
+	// latest tip build for each platform, as gotip.linux-amd64.zip. These
+	// are not in the checksum database.
+	MirrorFlat = "flat"
+	// MirrorProxy is a mirror laid out like a module proxy, as in
+	// <mirror>/golang.org/toolchain/@v/v0.0.1-go1.22.3.linux-amd64.zip.
+	MirrorProxy = "proxy"
+)
+
+// validateMirror reports an error if mirror is not an HTTP or HTTPS base
+// URL or layout is not a known layout.
+func validateMirror(mirror, layout string) error {
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return fmt.Errorf("invalid mirror URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid mirror URL %q: want an http:// or https:// URL", mirror)
+	}
+	switch layout {
+	case "", MirrorFlat, MirrorProxy:
+		return nil
+	}
+	return fmt.Errorf("unknown mirror layout %q: want %s or %s", layout, MirrorFlat, MirrorProxy)
+}
+
+// mirrorZipURL returns the URL of the zip of the toolchain module
+// version ver on mirror.
+func mirrorZipURL(mirror, layout, ver string) string {
+	mirror = strings.TrimSuffix(mirror, "/")
+	if layout == MirrorProxy {
+		return proxyZipURL(mirror, ver)
+	}
+	return fmt.Sprintf("%s/%s.zip", mirror, ver)
+}
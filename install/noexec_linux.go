@@ -0,0 +1,27 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"errors"
+	"syscall"
+)
+
+// stNoExec is the ST_NOEXEC mount flag of statfs(2).
+const stNoExec = 0x8
+
+// MountedNoExec reports whether the file system holding path is mounted
+// noexec.
+func MountedNoExec(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	return st.Flags&stNoExec != 0
+}
+
+// isExecFormatError reports whether err says a file is not an executable
+// for this machine.
+func isExecFormatError(err error) bool { return errors.Is(err, syscall.ENOEXEC) }
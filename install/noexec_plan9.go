@@ -0,0 +1,13 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+// MountedNoExec reports whether the file system holding path is mounted
+// noexec. Only Linux reports this.
+func MountedNoExec(path string) bool { return false }
+
+// isExecFormatError reports whether err says a file is not an executable
+// for this machine. Plan 9 has no errno for this.
+func isExecFormatError(err error) bool { return false }
@@ -0,0 +1,105 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// publishedPlatforms is the set of GOOS/GOARCH pairs for which Go
+// toolchains are published. See https://go.dev/dl/.
+var publishedPlatforms = map[string]bool{
+	"aix/ppc64":       true,
+	"darwin/amd64":    true,
+	"darwin/arm64":    true,
+	"dragonfly/amd64": true,
+	"freebsd/386":     true,
+	"freebsd/amd64":   true,
+	"freebsd/arm":     true,
+	"freebsd/arm64":   true,
+	"freebsd/riscv64": true,
+	"illumos/amd64":   true,
+	"linux/386":       true,
+	"linux/amd64":     true,
+	"linux/arm":       true,
+	"linux/arm64":     true,
+	"linux/loong64":   true,
+	"linux/mips":      true,
+	"linux/mips64":    true,
+	"linux/mips64le":  true,
+	"linux/mipsle":    true,
+	"linux/ppc64":     true,
+	"linux/ppc64le":   true,
+	"linux/riscv64":   true,
+	"linux/s390x":     true,
+	"netbsd/386":      true,
+	"netbsd/amd64":    true,
+	"netbsd/arm":      true,
+	"netbsd/arm64":    true,
+	"openbsd/386":     true,
+	"openbsd/amd64":   true,
+	"openbsd/arm":     true,
+	"openbsd/arm64":   true,
+	"plan9/386":       true,
+	"plan9/amd64":     true,
+	"plan9/arm":       true,
+	"solaris/amd64":   true,
+	"windows/386":     true,
+	"windows/amd64":   true,
+	"windows/arm":     true,
+	"windows/arm64":   true,
+}
+
+// ValidatePlatform reports an error if no Go toolchain is published
+// for goos/goarch.
+func ValidatePlatform(goos, goarch string) error {
+	if publishedPlatforms[goos+"/"+goarch] {
+		return nil
+	}
+	var known []string
+	for p := range publishedPlatforms {
+		if strings.HasPrefix(p, goos+"/") {
+			known = append(known, p)
+		}
+	}
+	if len(known) == 0 {
+		return fmt.Errorf("no Go toolchain is published for GOOS=%s", goos)
+	}
+	sort.Strings(known)
+	return fmt.Errorf("no Go toolchain is published for %s/%s; available for %s: %s", goos, goarch, goos, strings.Join(known, ", "))
+}
+
+// validateGOARM reports an error if goarm is not a GOARM value for which
+// a goarch toolchain can be built.
+func validateGOARM(goarch, goarm string) error {
+	if goarch != "arm" {
+		return fmt.Errorf("GOARM=%s applies to GOARCH=arm, not %s", goarm, goarch)
+	}
+	switch goarm {
+	case "5", "6", "7":
+		return nil
+	}
+	return fmt.Errorf("invalid GOARM=%s: want 5, 6 or 7", goarm)
+}
+
+// HostLibc returns the C library of the host: "musl" or "glibc" on
+// linux, judging by the dynamic loader installed in /lib, or empty if
+// it cannot be told or the host is not linux.
+func HostLibc() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	if m, _ := filepath.Glob("/lib/ld-musl-*"); len(m) > 0 {
+		return "musl"
+	}
+	if m, _ := filepath.Glob("/lib*/ld-linux*"); len(m) > 0 {
+		return "glibc"
+	}
+	return ""
+}
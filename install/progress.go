@@ -0,0 +1,79 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"io"
+	"sync"
+)
+
+// A ProgressFunc is called repeatedly during a long-running stage of an
+// installation with the number of bytes processed so far and the total,
+// or -1 if the total is unknown. It is called with done == total exactly
+// once, when the stage completes.
+type ProgressFunc func(stage string, done, total int64)
+
+// Stages reported to a ProgressFunc.
+const (
+	StageDownload = "download" // receiving the toolchain zip
+	StageExtract  = "extract"  // writing the files of the toolchain zip
+)
+
+// progressCounter counts the bytes processed during a stage and
+// reports them. It is safe for concurrent use.
+type progressCounter struct {
+	stage  string
+	total  int64
+	report ProgressFunc
+
+	mu       sync.Mutex
+	n        int64
+	finished bool
+}
+
+// reader returns a reader that counts the bytes read from r.
+func (c *progressCounter) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, c: c}
+}
+
+// add counts n more bytes as processed.
+func (c *progressCounter) add(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += n
+	switch {
+	case c.finished:
+	case c.n == c.total:
+		c.finishLocked()
+	case n > 0:
+		c.report(c.stage, c.n, c.total)
+	}
+}
+
+// finish reports the completion of the stage, if not done yet.
+func (c *progressCounter) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finishLocked()
+}
+
+func (c *progressCounter) finishLocked() {
+	if !c.finished {
+		c.finished = true
+		c.report(c.stage, c.n, c.n)
+	}
+}
+
+// progressReader counts the bytes read from r towards c.
+type progressReader struct {
+	r io.Reader
+	c *progressCounter
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.c.add(int64(n))
+	return n, err
+}
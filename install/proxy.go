@@ -0,0 +1,104 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// proxyEntry is an element of a GOPROXY list.
+type proxyEntry struct {
+	url string
+	// fallBackOnError reports whether the next entry is tried after any
+	// error, rather than only after a "not found" response. It is set
+	// for entries followed by a pipe instead of a comma.
+	fallBackOnError bool
+}
+
+// parseGOPROXY parses a GOPROXY value, using the same syntax as the go
+// command: proxy URLs separated by commas or pipes, and the special
+// values "direct" and "off".
+func parseGOPROXY(s string) []proxyEntry {
+	var entries []proxyEntry
+	for s != "" {
+		var url string
+		i := strings.IndexAny(s, ",|")
+		fallBack := false
+		if i < 0 {
+			url, s = s, ""
+		} else {
+			url, fallBack, s = s[:i], s[i] == '|', s[i+1:]
+		}
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		entries = append(entries, proxyEntry{url: strings.TrimSuffix(url, "/"), fallBackOnError: fallBack})
+	}
+	return entries
+}
+
+// proxyZipURL returns the URL of the zip of the toolchain module
+// version ver served by the proxy at base.
+func proxyZipURL(base, ver string) string {
+	return fmt.Sprintf("%s/%s/@v/%s.zip", base, gotoolchainModule, ver)
+}
+
+// proxyZipURLs returns the URLs of the zip of the toolchain module
+// version ver from each of the proxies in the GOPROXY list.
+func proxyZipURLs(goproxy, ver string) []string {
+	var urls []string
+	for _, e := range parseGOPROXY(goproxy) {
+		if e.url == "off" {
+			break
+		}
+		if e.url != "direct" {
+			urls = append(urls, proxyZipURL(e.url, ver))
+		}
+	}
+	return urls
+}
+
+// downloadFromProxies installs the toolchain module of p from the first
+// proxy in the GOPROXY list that serves it.
+func (p *Plan) downloadFromProxies(ctx context.Context, m *Manifest) error {
+	goproxy := p.opts.GOPROXY
+	entries := parseGOPROXY(goproxy)
+	if len(entries) == 0 {
+		return fmt.Errorf("GOPROXY=%q lists no proxies", goproxy)
+	}
+	var err error
+	for _, e := range entries {
+		switch e.url {
+		case "off":
+			if err == nil {
+				err = fmt.Errorf("module downloads disabled by GOPROXY=off")
+			}
+			return err
+		case "direct":
+			// The go command would fetch the module from its origin
+			// repository, which requires a version control tool.
+			err = fmt.Errorf("downloading %s directly from its origin is not supported; set GOPROXY to a module proxy", gotoolchainModule)
+			continue
+		}
+		err = p.download(ctx, proxyZipURL(e.url, p.module), m)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if !e.fallBackOnError && !isNotFound(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isNotFound reports whether err is due to the server responding that
+// the requested file does not exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrNotFetched) || errors.Is(err, ErrTimeout)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// A RateLimiter limits downloads to a number of bytes per second, in
+// bursts of at most a second's worth. It is a token bucket, and safe for
+// concurrent use: downloads sharing a limiter (see Options.RateLimiter)
+// together stay within its rate.
+type RateLimiter struct {
+	rate float64 // bytes per second
+
+	mu     sync.Mutex
+	tokens float64 // bytes that may be read without waiting; negative while in debt
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter admitting rate bytes per second,
+// which must be positive.
+func NewRateLimiter(rate int64) *RateLimiter {
+	return &RateLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// burst returns the largest read the limiter admits at once.
+func (l *RateLimiter) burst() int {
+	return max(int(l.rate), 1)
+}
+
+// wait takes n bytes from the bucket, sleeping until they are covered
+// by the rate or ctx is done.
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+	if debt <= 0 {
+		return nil
+	}
+	t := time.NewTimer(time.Duration(debt / l.rate * float64(time.Second)))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// rateReader reads from r no faster than its limiter allows.
+type rateReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (r *rateReader) Read(p []byte) (int, error) {
+	if b := r.limiter.burst(); len(p) > b {
+		p = p[:b]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,17 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import "syscall"
+
+// processIsTranslated reports whether the current process is an amd64
+// binary running under Rosetta translation on an arm64 Mac.
+// See https://developer.apple.com/documentation/apple-silicon/about-the-rosetta-translation-environment.
+func processIsTranslated() bool {
+	// sysctl.proc_translated is a 32-bit integer that is 1 for
+	// translated processes, and does not exist on Intel Macs.
+	v, err := syscall.Sysctl("sysctl.proc_translated")
+	return err == nil && len(v) > 0 && v[0] == 1
+}
@@ -0,0 +1,11 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin
+
+package install
+
+// processIsTranslated reports whether the current process is running
+// under Rosetta translation, which only exists on macOS.
+func processIsTranslated() bool { return false }
@@ -0,0 +1,202 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// GoBinary returns the path of the go command in the toolchain at dir.
+func GoBinary(dir string) string {
+	return filepath.Join(dir, "bin", "go"+exeSuffix)
+}
+
+// exeSuffix is the file name suffix of executables on the host.
+var exeSuffix string
+
+func init() {
+	if runtime.GOOS == "windows" {
+		exeSuffix = ".exe"
+	}
+}
+
+// GoVersion runs gobin version and returns the Go version it reports,
+// such as go1.22.3.
+func GoVersion(gobin string) (string, error) {
+	return runGoVersion(context.Background(), gobin)
+}
+
+func runGoVersion(ctx context.Context, gobin string) (string, error) {
+	out, err := exec.CommandContext(ctx, gobin, "version").Output()
+	if err != nil {
+		return "", err
+	}
+	// The output looks like "go version go1.22.3 linux/amd64".
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[0] != "go" || fields[1] != "version" {
+		return "", fmt.Errorf("unexpected output from %s version: %q", gobin, out)
+	}
+	return fields[2], nil
+}
+
+// versionMatches reports whether got, the version reported by go version
+// or the first line of a VERSION file, is version. Tip builds report
+// themselves as devel.
+func versionMatches(got, version string) bool {
+	if version == Tip {
+		return got == "devel" || strings.HasPrefix(got, "devel ")
+	}
+	return got == version
+}
+
+// tipCommit returns the commit of the tip build in dir, as recorded in
+// its VERSION file, e.g. "devel go1.23-3a8b2f1 Tue Jan 2 15:04:05 2024
+// +0000", or "" if it cannot be told.
+func tipCommit(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "VERSION"))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 || fields[0] != "devel" {
+		return ""
+	}
+	// Older builds write +3a8b2f1 instead of go1.23-3a8b2f1.
+	commit := strings.TrimPrefix(fields[1], "+")
+	if i := strings.LastIndex(commit, "-"); i >= 0 {
+		commit = commit[i+1:]
+	}
+	return commit
+}
+
+// runCheckTimeout bounds how long checkRuns waits for go version.
+const runCheckTimeout = 30 * time.Second
+
+// checkRuns checks that the goos/goarch go command gobin runs on this
+// machine and reports version. Its errors explain why the command does
+// not run, if that can be told from how it failed.
+func checkRuns(gobin, goos, goarch, version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), runCheckTimeout)
+	defer cancel()
+	got, err := runGoVersion(ctx, gobin)
+	if err == nil {
+		if !versionMatches(got, version) {
+			return fmt.Errorf("installed go command reports version %v, want %v", got, version)
+		}
+		return nil
+	}
+	hostOS, hostArch, _ := HostOSArch()
+	var (
+		ee     *exec.ExitError
+		reason string
+	)
+	switch {
+	case ctx.Err() != nil:
+		reason = fmt.Sprintf("go version did not finish within %v", runCheckTimeout)
+	case isExecFormatError(err):
+		reason = fmt.Sprintf("it is not an executable for this %s/%s machine", hostOS, hostArch)
+	case errors.Is(err, fs.ErrPermission):
+		if MountedNoExec(gobin) {
+			return fmt.Errorf("the installed go command cannot run because %s is on a file system mounted noexec; install into a directory on another file system, with -dir or GOINSTALLDIR", filepath.Dir(gobin))
+		}
+		reason = "permission denied, although its execute bits are set; the file system may not allow running programs"
+	case errors.Is(err, fs.ErrNotExist):
+		// The kernel reports a missing dynamic loader as if the
+		// executable itself did not exist.
+		if _, serr := os.Stat(gobin); serr == nil {
+			reason = "its dynamic loader or a library it needs is missing"
+		}
+	case errors.As(err, &ee):
+		reason = fmt.Sprintf("go version failed: %v\n%s", err, bytes.TrimSpace(ee.Stderr))
+	}
+	if reason == "" {
+		reason = err.Error()
+	}
+	msg := fmt.Sprintf("the installed %s/%s go command does not run: %s", goos, goarch, reason)
+	if goos != hostOS || goarch != hostArch {
+		msg += fmt.Sprintf("; this machine is %s/%s, try -os=%s -arch=%s", hostOS, hostArch, hostOS, hostArch)
+	}
+	return errors.New(msg)
+}
+
+// Verify checks the toolchain for version installed in dir and
+// returns a description of each problem found.
+func Verify(dir, version string) []string {
+	var problems []string
+
+	// The bootstrap toolchain only contains the go command, and is
+	// modified by go toolchain use after installation.
+	required := []string{"bin/go" + exeSuffix, "bin/gofmt" + exeSuffix, "pkg/tool"}
+	bootstrap := false
+	if data, err := os.ReadFile(filepath.Join(dir, "VERSION")); err == nil && strings.Contains(string(data), installerSuffix) {
+		required = required[:1]
+		bootstrap = true
+	}
+	for _, name := range required {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("missing %v", name))
+			continue
+		}
+		if !info.IsDir() {
+			if !isExecutable(info) {
+				problems = append(problems, fmt.Sprintf("%v is not executable", name))
+			}
+			continue
+		}
+		filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%v: %v", p, err))
+				return nil
+			}
+			if d.Type().IsRegular() {
+				if info, err := d.Info(); err == nil && !isExecutable(info) {
+					rel, _ := filepath.Rel(dir, p)
+					problems = append(problems, fmt.Sprintf("%v is not executable", filepath.ToSlash(rel)))
+				}
+			}
+			return nil
+		})
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	if m, err := ReadManifest(dir); err == nil && m.Checksum != "" && !bootstrap {
+		sum, err := hashInstalled(dir, gotoolchainModule, m.Module)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("hashing installed files: %v", err))
+		case sum != m.Checksum:
+			problems = append(problems, fmt.Sprintf("installed files have checksum %v, want %v", sum, m.Checksum))
+		}
+	}
+
+	got, err := GoVersion(GoBinary(dir))
+	switch {
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("go version failed: %v", err))
+	case !versionMatches(got, version):
+		problems = append(problems, fmt.Sprintf("go version reports %v, want %v", got, version))
+	}
+	return problems
+}
+
+// isExecutable reports whether the file described by info has any
+// execute bit set. File modes do not record this on Windows.
+func isExecutable(info fs.FileInfo) bool {
+	return runtime.GOOS == "windows" || info.Mode()&0o111 != 0
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// goVersionRE matches Go release versions such as go1.22.3, go1.21,
+// go1.21beta1 and go1.21rc2.
+var goVersionRE = regexp.MustCompile(`^go([1-9][0-9]*)\.(0|[1-9][0-9]*)(?:\.(0|[1-9][0-9]*)|(beta|rc)([1-9][0-9]*))?$`)
+
+// Tip is the version of the development builds of Go from the master
+// branch. Only flat mirrors (see MirrorFlat) serve them, and there is a
+// single, replaceable installation of tip, in VersionDir(root, Tip).
+const Tip = "gotip"
+
+// ValidateVersion reports an error if v is not a valid Go release
+// version, or Tip.
+func ValidateVersion(v string) error {
+	if v != Tip && !goVersionRE.MatchString(v) {
+		return fmt.Errorf("invalid Go version %q: want goX.Y.Z, goX.Y, goX.YbetaN, goX.YrcN or gotip", v)
+	}
+	return nil
+}
+
+// goVersion is a parsed Go release version.
+type goVersion struct {
+	major, minor, patch int
+	// pre is 0 for betas, 1 for release candidates and 2 for releases,
+	// so that pre-releases sort before the release they precede.
+	pre int
+	// preNum is the N in betaN or rcN.
+	preNum int
+}
+
+// parseVersion parses a Go release version such as go1.22.3 or go1.21rc2.
+// It reports false if v is not a valid version.
+func parseVersion(v string) (goVersion, bool) {
+	m := goVersionRE.FindStringSubmatch(v)
+	if m == nil {
+		return goVersion{}, false
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	gv := goVersion{
+		major:  atoi(m[1]),
+		minor:  atoi(m[2]),
+		patch:  atoi(m[3]),
+		pre:    2,
+		preNum: atoi(m[5]),
+	}
+	switch m[4] {
+	case "beta":
+		gv.pre = 0
+	case "rc":
+		gv.pre = 1
+	}
+	return gv, true
+}
+
+// stable reports whether v is a release rather than a beta or release candidate.
+func (v goVersion) stable() bool { return v.pre == 2 }
+
+// CompareVersions returns -1, 0, or +1 depending on whether a < b, a == b,
+// or a > b. Invalid versions sort before valid ones.
+func CompareVersions(a, b string) int {
+	va, oka := parseVersion(a)
+	vb, okb := parseVersion(b)
+	switch {
+	case !oka && !okb:
+		return 0
+	case !oka:
+		return -1
+	case !okb:
+		return +1
+	}
+	for _, d := range [...]int{
+		va.major - vb.major,
+		va.minor - vb.minor,
+		va.pre - vb.pre,
+		va.preNum - vb.preNum,
+		va.patch - vb.patch,
+	} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return +1
+		}
+	}
+	return 0
+}
+
+// releasesURL lists the published Go releases. See https://go.dev/dl/?mode=json.
+const releasesURL = "https://go.dev/dl/?mode=json&include=all"
+
+// release is an entry of the go.dev/dl JSON listing.
+type release struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+}
+
+// latest holds the release list once it has been fetched.
+var latest struct {
+	mu       sync.Mutex
+	releases []release // nil until fetched
+}
+
+// releasesCache is the release list cached in releasesCacheFile, with
+// the validators go.dev sent it with.
+type releasesCache struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Releases     []release `json:"releases"`
+}
+
+// releasesCacheFile is the file in a cache directory (see
+// Options.CacheDir) that the release list is cached in.
+const releasesCacheFile = "releases.json"
+
+// Releases returns the Go releases listed on go.dev, newest first,
+// fetched with client, or http.DefaultClient if client is nil.
+// Betas and release candidates are skipped unless unstable is true.
+// The release list is fetched at most once per process, though a failed
+// fetch is tried again by the next call. If cacheDir is set, the list is
+// cached there, and only downloaded again if it changed.
+func Releases(ctx context.Context, client *http.Client, cacheDir string, unstable bool) ([]string, error) {
+	f := newFetcher(client, 0, nil)
+	f.cacheDir = cacheDir
+	return f.releases(ctx, unstable)
+}
+
+// LatestVersion returns the first of the Releases.
+func LatestVersion(ctx context.Context, client *http.Client, cacheDir string, unstable bool) (string, error) {
+	f := newFetcher(client, 0, nil)
+	f.cacheDir = cacheDir
+	return f.latestVersion(ctx, unstable)
+}
+
+func (f *fetcher) releases(ctx context.Context, unstable bool) ([]string, error) {
+	// Concurrent callers wait for one fetch. A failed fetch is not
+	// remembered, so the next caller tries again with its own ctx.
+	latest.mu.Lock()
+	if latest.releases == nil {
+		rs, err := f.fetchReleases(ctx)
+		if err != nil {
+			latest.mu.Unlock()
+			return nil, err
+		}
+		latest.releases = rs
+	}
+	releases := latest.releases
+	latest.mu.Unlock()
+	var versions []string
+	for _, r := range releases {
+		v, ok := parseVersion(r.Version)
+		if !ok || (!unstable && !v.stable()) {
+			continue
+		}
+		versions = append(versions, r.Version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) > 0
+	})
+	return versions, nil
+}
+
+func (f *fetcher) latestVersion(ctx context.Context, unstable bool) (string, error) {
+	versions, err := f.releases(ctx, unstable)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no Go release found at %s", releasesURL)
+	}
+	return versions[0], nil
+}
+
+// fetchReleases downloads the release list. A copy cached in f.cacheDir
+// is revalidated with a conditional request, and used if it is current.
+func (f *fetcher) fetchReleases(ctx context.Context) ([]release, error) {
+	var cached *releasesCache
+	header := make(http.Header)
+	file := filepath.Join(f.cacheDir, releasesCacheFile)
+	if f.cacheDir != "" {
+		if data, err := os.ReadFile(file); err == nil {
+			c := new(releasesCache)
+			if json.Unmarshal(data, c) == nil && (c.ETag != "" || c.LastModified != "") {
+				cached = c
+			}
+		}
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		} else {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	r, err := f.get(ctx, releasesURL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(r.Body)
+	if r.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.Releases, nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var releases []release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", releasesURL, err)
+	}
+	if f.cacheDir != "" {
+		c := &releasesCache{ETag: r.Header.Get("ETag"), LastModified: r.Header.Get("Last-Modified"), Releases: releases}
+		// The cache is only an optimization.
+		_ = writeReleasesCache(file, c)
+	}
+	return releases, nil
+}
+
+// writeReleasesCache atomically writes c to file.
+func writeReleasesCache(file string, c *releasesCache) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), releasesCacheFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestReleasesRetriesFailure(t *testing.T) {
+	latest.releases = nil
+	t.Cleanup(func() { latest.releases = nil })
+
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"version": "go1.22.3", "stable": true}, {"version": "go1.23rc1", "stable": false}]`))
+	}))
+	defer srv.Close()
+	// Send the requests for go.dev to srv.
+	u, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: rewriteHost{u.Host}}
+
+	if _, err := LatestVersion(context.Background(), client, "", false); err == nil {
+		t.Fatalf("LatestVersion succeeded with a failing server")
+	}
+	fail = false
+	if v, err := LatestVersion(context.Background(), client, "", false); err != nil || v != "go1.22.3" {
+		t.Errorf("LatestVersion after a failure = %v, %v; want go1.22.3", v, err)
+	}
+	// The list is now remembered.
+	fail = true
+	if v, err := LatestVersion(context.Background(), client, "", true); err != nil || v != "go1.23rc1" {
+		t.Errorf("LatestVersion(unstable) = %v, %v; want go1.23rc1 from the remembered list", v, err)
+	}
+}
+
+// rewriteHost is a transport that sends all requests to host over plain
+// HTTP.
+type rewriteHost struct{ host string }
+
+func (t rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = "http", t.host
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,564 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ReadZip downloads the zip archive at u into memory with client, or
+// http.DefaultClient if client is nil, reporting the StageDownload
+// progress to progress if it is non-nil. Unlike Install, it does not
+// retry.
+func ReadZip(ctx context.Context, client *http.Client, u string, progress ProgressFunc) (*zip.Reader, error) {
+	return newFetcher(client, 0, progress).readZip(ctx, u)
+}
+
+func (f *fetcher) readZip(ctx context.Context, u string) (*zip.Reader, error) {
+	var bodyBytes []byte
+	err := f.executeRequest(ctx, u, true, func(body io.Reader) error {
+		var err error
+		bodyBytes, err = io.ReadAll(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(bodyBytes), int64(len(bodyBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("%s did not return a zip file: %v", u, err)
+	}
+	return zipReader, nil
+}
+
+// ZipFile is a zip archive downloaded to a temporary file.
+type ZipFile struct {
+	*zip.ReadCloser
+	path string
+	// sha256 is the SHA-256 of the file in hex, computed while it was
+	// downloaded, or empty if it is not known.
+	sha256 string
+}
+
+// Close closes the archive and removes the temporary file.
+func (z *ZipFile) Close() error {
+	err := z.ReadCloser.Close()
+	if rmErr := os.Remove(z.path); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// DownloadZip downloads the zip archive at u to a temporary file and
+// opens it. Unlike ReadZip, it does not hold the archive in memory.
+// The caller must Close the returned ZipFile.
+// As with ReadZip, client and progress may be nil.
+func DownloadZip(ctx context.Context, client *http.Client, u string, progress ProgressFunc) (*ZipFile, error) {
+	return newFetcher(client, 0, progress).downloadZip(ctx, u)
+}
+
+func (f *fetcher) downloadZip(ctx context.Context, u string) (_ *ZipFile, err error) {
+	tmp, err := os.CreateTemp("", "goup-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	sum, err := f.downloadFile(ctx, u, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc, err := zip.OpenReader(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("%s did not return a zip file: %v", u, err)
+	}
+	return &ZipFile{ReadCloser: rc, path: tmp.Name(), sha256: sum}, nil
+}
+
+// toolchainPrefix returns the directory that all entries of archive are
+// in, to be stripped when it is extracted: mod, the mod@version/ directory
+// of a module zip, or go/, as in the archives on go.dev/dl. It returns ""
+// if the entries are not all in one of them.
+func toolchainPrefix(archive *zip.Reader, mod string) string {
+	for _, prefix := range []string{mod, "go/"} {
+		all := len(archive.File) > 0
+		for _, f := range archive.File {
+			if !strings.HasPrefix(f.Name, prefix) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// checkToolchainZip reports an error if archive does not hold a Go
+// toolchain: a VERSION file and a go command, under prefix, as returned
+// by toolchainPrefix. This catches a misconfigured server answering with
+// some other zip before anything is extracted.
+func checkToolchainZip(archive *zip.Reader, prefix string) error {
+	var version, gobin bool
+	for _, f := range archive.File {
+		switch strings.TrimPrefix(f.Name, prefix) {
+		case "VERSION":
+			version = true
+		case "bin/go", "bin/go.exe":
+			gobin = true
+		}
+	}
+	switch {
+	case !version:
+		return errors.New("downloaded archive does not look like a Go toolchain: it has no VERSION file")
+	case !gobin:
+		return errors.New("downloaded archive does not look like a Go toolchain: it has no bin/go")
+	}
+	return nil
+}
+
+// Default limits on the size of the archives WriteZip extracts. A Go
+// toolchain unpacks to a few hundred megabytes, and its largest file is
+// a few tens of megabytes.
+const (
+	DefaultMaxSize     = 2 << 30
+	DefaultMaxFileSize = 512 << 20
+)
+
+// ZipLimits bounds the uncompressed size of the archives WriteZip
+// extracts, protecting against corrupt archives and zip bombs.
+// A zero field selects the default.
+type ZipLimits struct {
+	MaxSize     int64 // of all files together; default DefaultMaxSize
+	MaxFileSize int64 // of each file; default DefaultMaxFileSize
+}
+
+// check returns an error if the sizes declared in archive exceed l.
+// Otherwise, it returns their total.
+func (l ZipLimits) check(archive *zip.Reader) (int64, error) {
+	maxSize, maxFileSize := l.MaxSize, l.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+	var total int64
+	for _, f := range archive.File {
+		// Compare as uint64 so that sizes beyond the range of int64
+		// cannot wrap around.
+		if f.UncompressedSize64 > uint64(maxFileSize) {
+			return 0, fmt.Errorf("%s in archive is too large: %d bytes uncompressed, limit %d", f.Name, f.UncompressedSize64, maxFileSize)
+		}
+		if int64(f.UncompressedSize64) > maxSize-total {
+			return 0, fmt.Errorf("archive is too large: more than %d bytes uncompressed", maxSize)
+		}
+		total += int64(f.UncompressedSize64)
+	}
+	return total, nil
+}
+
+// maxExtractWorkers bounds the number of files WriteZip writes
+// concurrently.
+const maxExtractWorkers = 8
+
+// WriteZip extracts archive into the directory dst, which is created
+// if needed. If stripPrefix is set, e.g. to "go/", the entries must be
+// under that directory, which is left out of their paths: the archive
+// entry go/bin/go is written to dst/bin/go. Entries that would be written
+// outside dst are rejected, as are archives whose files exceed limits.
+// No entry is written beyond the size recorded for it in the archive.
+// If progress is non-nil, the uncompressed bytes written are reported
+// to it as StageExtract.
+//
+// Files are written concurrently. Their modes and modification times are
+// those recorded in the archive; SetExecutable can be used to fix up the
+// modes of module zips afterwards. If ctx is done before all files are
+// written, WriteZip returns its error and leaves dst incomplete.
+func WriteZip(ctx context.Context, dst, stripPrefix string, archive *zip.Reader, limits ZipLimits, progress ProgressFunc) error {
+	return writeZip(ctx, dst, stripPrefix, archive, limits, progress, false)
+}
+
+// writeZip is WriteZip. If resume is set, files that already exist in
+// dst with the content of their archive entries are left alone.
+func writeZip(ctx context.Context, dst, stripPrefix string, archive *zip.Reader, limits ZipLimits, progress ProgressFunc, resume bool) error {
+	total, err := limits.check(archive)
+	if err != nil {
+		return err
+	}
+	// names are the paths of the entries relative to dst, or "" for
+	// the stripped directory itself.
+	names := make([]string, len(archive.File))
+	for i, f := range archive.File {
+		name, ok := strings.CutPrefix(f.Name, stripPrefix)
+		if !ok {
+			return fmt.Errorf("%q in archive is not under %s", f.Name, stripPrefix)
+		}
+		names[i] = name
+	}
+	if err := os.MkdirAll(dst, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	// Toolchain zips hold thousands of files in comparatively few
+	// directories, so remember which ones exist already instead of
+	// calling MkdirAll for every file.
+	var mu sync.Mutex
+	made := map[string]bool{filepath.Clean(dst): true}
+	mkdirAll := func(dir string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if made[dir] {
+			return nil
+		}
+		// MkdirAll follows symlinks, which could lead out of dst.
+		if err := checkNoSymlinks(dir, func(d string) bool { return made[d] }); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+		// All entries are inside dst, so this stops there at the latest.
+		for ; !made[dir]; dir = filepath.Dir(dir) {
+			made[dir] = true
+		}
+		return nil
+	}
+	var p *progressCounter
+	if progress != nil {
+		p = &progressCounter{stage: StageExtract, total: total, report: progress}
+	}
+
+	extract := func(f *zip.File, name string) error {
+		filePath := filepath.Join(dst, filepath.FromSlash(name))
+		if f.FileInfo().IsDir() {
+			return mkdirAll(filePath)
+		}
+		if err := mkdirAll(filepath.Dir(filePath)); err != nil {
+			return err
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			return writeSymlink(dst, filePath, f, p)
+		}
+		if resume && extracted(filePath, f) {
+			if p != nil {
+				p.add(int64(f.UncompressedSize64))
+			}
+			return nil
+		}
+		return writeFile(filePath, f, p)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		stop     = make(chan struct{}) // closed on the first error
+		files    = make(chan int)      // indexes into archive.File
+		links    []int                 // the symlinks, written last
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			close(stop)
+		})
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > maxExtractWorkers {
+		workers = maxExtractWorkers
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range files {
+				f := archive.File[i]
+				if err := extract(f, names[i]); err != nil {
+					fail(fmt.Errorf("extracting %q: %w", f.Name, err))
+				}
+			}
+		}()
+	}
+send:
+	for i, f := range archive.File {
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			break
+		}
+		if names[i] == "" {
+			continue
+		}
+		// Archive paths always use forward slashes. Check them in the
+		// form they are written in, so that neither separator can be
+		// used to escape dst, nor a drive letter or reserved name on
+		// Windows.
+		if !filepath.IsLocal(filepath.FromSlash(names[i])) {
+			fail(fmt.Errorf("illegal file path %q in archive", f.Name))
+			break
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			links = append(links, i)
+			continue
+		}
+		select {
+		case files <- i:
+		case <-stop:
+			break send
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break send
+		}
+	}
+	close(files)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	// Symlinks are created after all other entries, one at a time, so
+	// that no file is written through one, and a link cannot be placed
+	// inside another to reach outside dst.
+	for _, i := range links {
+		f := archive.File[i]
+		if err := extract(f, names[i]); err != nil {
+			return fmt.Errorf("extracting %q: %w", f.Name, err)
+		}
+	}
+	// Writing the files changed the modification times of their
+	// directories, so restore those last.
+	for i, f := range archive.File {
+		if f.FileInfo().IsDir() && !f.Modified.IsZero() {
+			if err := os.Chtimes(filepath.Join(dst, filepath.FromSlash(names[i])), f.Modified, f.Modified); err != nil {
+				return fmt.Errorf("extracting %q: %w", f.Name, err)
+			}
+		}
+	}
+	if p != nil {
+		p.finish()
+	}
+	return nil
+}
+
+// writeSymlink creates the symlink stored in the archive entry f at
+// filePath, counting it towards p if it is non-nil. The link target is
+// the content of the entry, and must not point outside dst.
+//
+// The directories containing filePath must not be symlinks, so that the
+// target is resolved against the real parent. It is stored cleaned: a
+// ".." after a link would otherwise go up from where that link points,
+// not from where it is.
+func writeSymlink(dst, filePath string, f *zip.File, p *progressCounter) error {
+	// Earlier links may have replaced directories known to exist, so
+	// check all the way up to dst.
+	dst = filepath.Clean(dst)
+	if err := checkNoSymlinks(filepath.Dir(filePath), func(d string) bool { return d == dst }); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	target, err := io.ReadAll(io.LimitReader(r, int64(f.UncompressedSize64)))
+	if err != nil {
+		return err
+	}
+	t := filepath.Clean(filepath.FromSlash(string(target)))
+	if filepath.IsAbs(t) || filepath.VolumeName(t) != "" || !strings.HasPrefix(filepath.Join(filepath.Dir(filePath), t), filepath.Clean(dst)+string(os.PathSeparator)) {
+		return fmt.Errorf("illegal symlink target %q in archive", target)
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Symlink(t, filePath); err != nil {
+		return err
+	}
+	if p != nil {
+		p.add(int64(f.UncompressedSize64))
+	}
+	return nil
+}
+
+// checkNoSymlinks returns an error if dir or one of its parents is a
+// symlink, checking up to the first directory for which stop is true.
+// Directories that do not exist yet are fine.
+func checkNoSymlinks(dir string, stop func(string) bool) error {
+	for d := dir; !stop(d); d = filepath.Dir(d) {
+		if fi, err := os.Lstat(d); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", d)
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return nil
+}
+
+// extracted reports whether filePath holds the content of the archive
+// entry f, as far as its size and CRC-32 tell.
+func extracted(filePath string, f *zip.File) bool {
+	if fi, err := os.Lstat(filePath); err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	if fi, err := file.Stat(); err != nil || !fi.Mode().IsRegular() || uint64(fi.Size()) != f.UncompressedSize64 {
+		return false
+	}
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, file); err != nil {
+		return false
+	}
+	return h.Sum32() == f.CRC32
+}
+
+// writeFile writes the contents of the archive entry f to filePath,
+// counting them towards p if it is non-nil.
+func writeFile(filePath string, f *zip.File, p *progressCounter) error {
+	// Replace a symlink left in the way rather than write through it.
+	info, err := os.Lstat(filePath)
+	existed := err == nil
+	if existed && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+		existed = false
+	}
+	const flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	dstFile, err := os.OpenFile(filePath, flag, f.Mode())
+	if errors.Is(err, fs.ErrPermission) {
+		// A read-only file left by an earlier installation, such as a
+		// 0555 binary, is made writable to be overwritten. Its mode is
+		// set as intended once it is written.
+		if existed && info.Mode().IsRegular() {
+			if cerr := os.Chmod(filePath, info.Mode().Perm()|0o200); cerr == nil {
+				dstFile, err = os.OpenFile(filePath, flag, f.Mode())
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	fileInArchive, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer fileInArchive.Close()
+
+	var src io.Reader = fileInArchive
+	if p != nil {
+		src = p.reader(fileInArchive)
+	}
+	// The entry may hold more data than it claims; write no more than
+	// the declared size, which ZipLimits.check has accepted. Reading on
+	// to the end then verifies the CRC and fails if data is left over.
+	if _, err := io.CopyN(dstFile, src, int64(f.UncompressedSize64)); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(fileInArchive, make([]byte, 1)); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("more data than the %d bytes declared", f.UncompressedSize64)
+		}
+		return err
+	}
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+	// OpenFile only applies the mode to new files.
+	if existed {
+		if err := os.Chmod(filePath, f.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if f.Modified.IsZero() {
+		return nil
+	}
+	return os.Chtimes(filePath, f.Modified, f.Modified)
+}
+
+// SetExecutable sets the execute bits on the commands of the toolchain
+// gotoolchain extracted into dir, as the go command does for the
+// toolchains it downloads. Module zips do not record file modes.
+func SetExecutable(gotoolchain, dir string) error {
+	// On first use after download, set the execute bits on the commands
+	// so that we can run them. Note that multiple go commands might be
+	// doing this at the same time, but if so no harm done.
+
+	// Windows has no execute bits, but the toolchain must still have
+	// its go command. This also covers Windows toolchains installed on
+	// other platforms (see Options.GOOS).
+	if _, err := os.Stat(filepath.Join(dir, "bin", "go.exe")); err == nil {
+		return nil
+	} else if runtime.GOOS == "windows" {
+		return fmt.Errorf("download %s: %v", gotoolchain, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "bin/go"))
+	if err != nil {
+		return fmt.Errorf("download %s: %v", gotoolchain, err)
+	}
+	if info.Mode()&0111 != 0 {
+		return nil
+	}
+	// allowExec sets the exec permission bits on all files found in dir.
+	allowExec := func(dir string) error {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				if err := os.Chmod(path, info.Mode()&0777|0111); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("download %s: %v", gotoolchain, err)
+		}
+		return nil
+	}
+
+	// Set the bits in pkg/tool before bin/go.
+	// If we are racing with another go command and do bin/go first,
+	// then the check of bin/go above might succeed, the other go command
+	// would skip its own mode-setting, and then the go command might
+	// try to run a tool before we get to setting the bits on pkg/tool.
+	// Setting pkg/tool before bin/go avoids that ordering problem.
+	// The only other tool the go command invokes is gofmt,
+	// so we set that one explicitly before handling bin (which will include bin/go).
+	for _, d := range []string{"pkg/tool", "bin/gofmt", "bin"} {
+		if err := allowExec(filepath.Join(dir, d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
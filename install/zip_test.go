@@ -0,0 +1,424 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package install
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A zipEntry describes a file to put in a test archive.
+type zipEntry struct {
+	name     string
+	body     string      // the link target for symlinks
+	mode     fs.FileMode // 0 means 0644 for files and 0755 for directories
+	modified time.Time
+}
+
+// makeZip returns an archive holding entries.
+func makeZip(t testing.TB, entries ...zipEntry) *zip.Reader {
+	t.Helper()
+	data := zipData(t, entries...)
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// zipData returns the content of an archive holding entries.
+func zipData(t testing.TB, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: e.modified}
+		mode := e.mode
+		switch {
+		case mode == 0 && e.name[len(e.name)-1] == '/':
+			mode = fs.ModeDir | 0o755
+		case mode == 0:
+			mode = 0o644
+		}
+		h.SetMode(mode)
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteZipSymlinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	for _, tc := range []struct {
+		name    string
+		entries []zipEntry
+	}{
+		{"absolute", []zipEntry{
+			{name: "link", body: "/etc", mode: fs.ModeSymlink | 0o777},
+			{name: "link/pwned", body: "x"},
+		}},
+		{"dotdot", []zipEntry{
+			{name: "link", body: "../outside", mode: fs.ModeSymlink | 0o777},
+		}},
+		{"file through link", []zipEntry{
+			{name: "d/x", body: "..", mode: fs.ModeSymlink | 0o777},
+			{name: "d/x/pwned", body: "x"},
+		}},
+		{"chain", []zipEntry{
+			{name: "d/x", body: "../a", mode: fs.ModeSymlink | 0o777},
+			{name: "d/x/y", body: "../../outside", mode: fs.ModeSymlink | 0o777},
+			{name: "d/x/y/pwned", body: "x"},
+		}},
+		{"link replacing a directory", []zipEntry{
+			{name: "a/", mode: fs.ModeDir | 0o755},
+			{name: "d/x/", mode: fs.ModeDir | 0o755},
+			{name: "d/x", body: "../a", mode: fs.ModeSymlink | 0o777},
+			{name: "d/x/y", body: "../../outside", mode: fs.ModeSymlink | 0o777},
+		}},
+		{"dotdot after link", []zipEntry{
+			{name: "d/e/x", body: "..", mode: fs.ModeSymlink | 0o777},
+			{name: "d/e/y", body: "x/../../../outside", mode: fs.ModeSymlink | 0o777},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The links point at files named outside, which exist in
+			// the directories above dst so that the links resolve.
+			root := t.TempDir()
+			dst := filepath.Join(root, "p1", "p2", "dst")
+			if err := os.MkdirAll(dst, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for _, dir := range []string{root, filepath.Dir(dst), filepath.Dir(filepath.Dir(dst))} {
+				if err := os.WriteFile(filepath.Join(dir, "outside"), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := WriteZip(context.Background(), dst, "", makeZip(t, tc.entries...), ZipLimits{}, nil)
+			// Whether or not WriteZip reports an error, nothing may
+			// appear outside dst, and no link may resolve outside it.
+			filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if path == dst {
+					return filepath.SkipDir
+				}
+				if name := d.Name(); name == "pwned" || name == "a" {
+					t.Errorf("WriteZip wrote %s outside dst", path)
+				}
+				return nil
+			})
+			filepath.WalkDir(dst, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.Type()&fs.ModeSymlink == 0 {
+					return err
+				}
+				// A link that escapes reaches one of the outside files;
+				// one that dangles points at nothing above dst.
+				real, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					return nil
+				}
+				if rel, err := filepath.Rel(dst, real); err != nil || !filepath.IsLocal(rel) {
+					t.Errorf("%s resolves to %s, outside dst", path, real)
+				}
+				return nil
+			})
+			// The last link is fine once its target is cleaned.
+			if err == nil && tc.name != "dotdot after link" {
+				t.Errorf("WriteZip succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestWriteZipSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dst := t.TempDir()
+	archive := makeZip(t,
+		zipEntry{name: "lib/link", body: "../src/file", mode: fs.ModeSymlink | 0o777},
+		zipEntry{name: "src/file", body: "hello"},
+	)
+	if err := WriteZip(context.Background(), dst, "", archive, ZipLimits{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "lib", "link"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("reading lib/link = %q, %v; want %q", data, err, "hello")
+	}
+}
+
+func TestCheckToolchainZip(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []zipEntry
+		prefix  string
+		wantErr string // "" for success
+	}{
+		{"toolchain", []zipEntry{{name: "go/VERSION", body: "go1.22.3"}, {name: "go/bin/go"}}, "go/", ""},
+		{"windows toolchain", []zipEntry{{name: "VERSION", body: "go1.22.3"}, {name: "bin/go.exe"}}, "", ""},
+		{"no VERSION", []zipEntry{{name: "go/bin/go"}}, "go/", "no VERSION file"},
+		{"no go command", []zipEntry{{name: "go/VERSION"}, {name: "go/bin/gofmt"}}, "go/", "no bin/go"},
+		{"bogus", []zipEntry{{name: "index.html", body: "<html>"}}, "", "no VERSION file"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkToolchainZip(makeZip(t, tc.entries...), tc.prefix)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("checkToolchainZip: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("checkToolchainZip = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestToolchainPrefix(t *testing.T) {
+	const mod = "golang.org/toolchain@v0.0.1-go1.22.3.linux-amd64/"
+	for _, tc := range []struct {
+		name   string
+		prefix string // of the entries
+		want   string // from toolchainPrefix
+	}{
+		{"module zip", mod, mod},
+		{"go.dev/dl zip", "go/", "go/"},
+		{"no prefix", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := makeZip(t,
+				zipEntry{name: tc.prefix + "VERSION", body: "go1.22.3"},
+				zipEntry{name: tc.prefix + "bin/go", body: "go", mode: 0o755},
+				zipEntry{name: tc.prefix + "src/fmt/print.go", body: "package fmt"},
+			)
+			prefix := toolchainPrefix(archive, mod)
+			if prefix != tc.want {
+				t.Fatalf("toolchainPrefix = %q, want %q", prefix, tc.want)
+			}
+			dst := t.TempDir()
+			if err := WriteZip(context.Background(), dst, prefix, archive, ZipLimits{}, nil); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"VERSION", "bin/go", "src/fmt/print.go"} {
+				if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s not extracted: %v", name, err)
+				}
+			}
+			for _, dir := range []string{"go", "golang.org"} {
+				if _, err := os.Stat(filepath.Join(dst, dir)); err == nil {
+					t.Errorf("%s extracted with its %s/ prefix", tc.name, dir)
+				}
+			}
+		})
+	}
+}
+
+func TestToolchainPrefixMixed(t *testing.T) {
+	// An archive with entries both in and outside go/ is not stripped.
+	archive := makeZip(t, zipEntry{name: "go/VERSION"}, zipEntry{name: "README"})
+	if prefix := toolchainPrefix(archive, "golang.org/toolchain@v0.0.1-go1.22.3.linux-amd64/"); prefix != "" {
+		t.Errorf("toolchainPrefix = %q, want \"\"", prefix)
+	}
+	if err := WriteZip(context.Background(), t.TempDir(), "go/", archive, ZipLimits{}, nil); err == nil {
+		t.Errorf("WriteZip with go/ stripped succeeded for an entry outside go/")
+	}
+}
+
+func TestWriteZipOverReadOnly(t *testing.T) {
+	dst := t.TempDir()
+	file := filepath.Join(dst, "bin", "go")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("old"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	archive := makeZip(t, zipEntry{name: "bin/go", body: "new", mode: 0o555})
+	if err := WriteZip(context.Background(), dst, "", archive, ZipLimits{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || string(data) != "new" {
+		t.Errorf("bin/go = %q, %v; want %q", data, err, "new")
+	}
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o555 {
+			t.Errorf("bin/go mode = %v, want %v", fi.Mode().Perm(), fs.FileMode(0o555))
+		}
+	}
+}
+
+func TestWriteZipModTimes(t *testing.T) {
+	fileTime := time.Date(2024, 5, 7, 16, 3, 21, 0, time.UTC)
+	dirTime := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	archive := makeZip(t,
+		zipEntry{name: "src/", modified: dirTime},
+		zipEntry{name: "src/fmt/", modified: dirTime},
+		zipEntry{name: "src/fmt/print.go", body: "package fmt", modified: fileTime},
+		zipEntry{name: "VERSION", body: "go1.22.3", modified: fileTime},
+	)
+	dst := t.TempDir()
+	if err := WriteZip(context.Background(), dst, "", archive, ZipLimits{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]time.Time{
+		"src":              dirTime,
+		"src/fmt":          dirTime,
+		"src/fmt/print.go": fileTime,
+		"VERSION":          fileTime,
+	} {
+		fi, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := fi.ModTime(); !got.Equal(want) {
+			t.Errorf("%s modified %v, want %v", name, got.UTC(), want)
+		}
+	}
+}
+
+func TestSetExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no execute bits")
+	}
+	// Module zips record no modes, so everything is extracted 0644.
+	archive := makeZip(t,
+		zipEntry{name: "VERSION", body: "go1.22.3"},
+		zipEntry{name: "bin/go"},
+		zipEntry{name: "bin/gofmt"},
+		zipEntry{name: "pkg/tool/linux_amd64/compile"},
+		zipEntry{name: "src/fmt/print.go"},
+	)
+	dst := t.TempDir()
+	if err := WriteZip(context.Background(), dst, "", archive, ZipLimits{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetExecutable("go1.22.3", dst); err != nil {
+		t.Fatal(err)
+	}
+	for name, exec := range map[string]bool{
+		"bin/go":                       true,
+		"bin/gofmt":                    true,
+		"pkg/tool/linux_amd64/compile": true,
+		"VERSION":                      false,
+		"src/fmt/print.go":             false,
+	} {
+		fi, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got := fi.Mode()&0o111 == 0o111; got != exec {
+			t.Errorf("%s has mode %v; executable = %v, want %v", name, fi.Mode(), got, exec)
+		}
+	}
+}
+
+func TestSetExecutableNoGo(t *testing.T) {
+	if err := SetExecutable("go1.22.3", t.TempDir()); err == nil {
+		t.Errorf("SetExecutable succeeded for a directory without bin/go")
+	}
+}
+
+func TestWriteZipWindowsPaths(t *testing.T) {
+	// Backslashes, drive letters and reserved names only mean something
+	// on Windows; elsewhere they are parts of ordinary file names.
+	for _, tc := range []struct {
+		name                  string
+		wantErr, wantErrOnWin bool
+	}{
+		{name: `..\evil`, wantErrOnWin: true},
+		{name: `bin\..\..\evil`, wantErrOnWin: true},
+		{name: `\\server\share\evil`, wantErrOnWin: true},
+		{name: `C:/evil`, wantErrOnWin: true},
+		{name: `C:evil`, wantErrOnWin: true},
+		{name: `NUL`, wantErrOnWin: true},
+		{name: `bin/COM1`, wantErrOnWin: true},
+		{name: `//server/share/evil`, wantErr: true, wantErrOnWin: true},
+		{name: `../evil`, wantErr: true, wantErrOnWin: true},
+		{name: `/evil`, wantErr: true, wantErrOnWin: true},
+		{name: `bin/go.exe`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := tc.wantErr
+			if runtime.GOOS == "windows" {
+				want = tc.wantErrOnWin
+			}
+			err := WriteZip(context.Background(), t.TempDir(), "", makeZip(t, zipEntry{name: tc.name}), ZipLimits{}, nil)
+			if gotErr := err != nil; gotErr != want {
+				t.Errorf("WriteZip with %q: error = %v, want error %v", tc.name, err, want)
+			}
+		})
+	}
+}
+
+func TestSetExecutableWindowsToolchain(t *testing.T) {
+	// A Windows toolchain, installed on any platform, has bin/go.exe
+	// and no execute bits to set.
+	dir := t.TempDir()
+	archive := makeZip(t, zipEntry{name: "VERSION", body: "go1.22.3"}, zipEntry{name: "bin/go.exe"})
+	if err := WriteZip(context.Background(), dir, "", archive, ZipLimits{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetExecutable("go1.22.3", dir); err != nil {
+		t.Errorf("SetExecutable: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(filepath.Join(dir, "bin", "go.exe"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode()&0o111 != 0 {
+			t.Errorf("bin/go.exe mode = %v, want it left alone", fi.Mode())
+		}
+	}
+}
+
+// BenchmarkWriteZip extracts an archive shaped like a toolchain: many
+// small files in comparatively few directories.
+func BenchmarkWriteZip(b *testing.B) {
+	var entries []zipEntry
+	for d := 0; d < 100; d++ {
+		for f := 0; f < 50; f++ {
+			entries = append(entries, zipEntry{
+				name: fmt.Sprintf("go/src/pkg%d/sub%d/file%d.go", d, d%7, f),
+				body: strings.Repeat("package p\n", 100),
+			})
+		}
+	}
+	archive := makeZip(b, entries...)
+	dir := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteZip(context.Background(), filepath.Join(dir, strconv.Itoa(i)), "go/", archive, ZipLimits{}, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
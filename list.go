@@ -0,0 +1,59 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyangah/goup/install"
+)
+
+var listFlags = flag.NewFlagSet("goup list", flag.ExitOnError)
+
+func init() {
+	addDirFlag(listFlags)
+}
+
+// runList implements the list command.
+func runList(ctx context.Context, args []string) error {
+	listFlags.Parse(args)
+
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+	installs, err := install.InstalledVersions(root)
+	if err != nil {
+		return err
+	}
+	if len(installs) == 0 {
+		fmt.Println("No Go installations found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "\tVERSION\tPATH")
+	for _, in := range installs {
+		mark := ""
+		if in.Active {
+			mark = "*"
+		}
+		version := in.Version
+		if in.GOOS != "" {
+			version += " for " + in.GOOS + "/" + in.GOARCH
+		}
+		if in.Commit != "" {
+			version += " (" + in.Commit + ")"
+		}
+		if in.Default {
+			version += " (default)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", mark, version, in.Dir)
+	}
+	return w.Flush()
+}
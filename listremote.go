@@ -0,0 +1,61 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/hyangah/goup/install"
+)
+
+var (
+	listRemoteFlags = flag.NewFlagSet("goup list-remote", flag.ExitOnError)
+	stableFlag      = listRemoteFlags.Bool("stable", false, "List only releases, not betas and release candidates.")
+)
+
+func init() {
+	addDirFlag(listRemoteFlags)
+}
+
+// runListRemote implements the list-remote command.
+func runListRemote(ctx context.Context, args []string) error {
+	listRemoteFlags.Parse(args)
+
+	// The module proxy is no help here: its version list for
+	// golang.org/toolchain is incomplete.
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+	versions, err := install.Releases(ctx, httpClient, filepath.Join(root, "cache"), !*stableFlag)
+	if err != nil {
+		return fmt.Errorf("listing the available Go versions: %v", err)
+	}
+	installs, err := install.InstalledVersions(root)
+	if err != nil {
+		return err
+	}
+	installed := make(map[string]bool)
+	for _, in := range installs {
+		if in.GOOS == "" {
+			installed[in.Version] = true
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, v := range versions {
+		mark := ""
+		if installed[v] {
+			mark = "installed"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", v, mark)
+	}
+	return w.Flush()
+}
@@ -5,33 +5,21 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"io/fs"
-	"log"
-	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"golang.org/x/net/context/ctxhttp"
-)
-
-const (
-	// We download golang.org/toolchain version v0.0.1-<gotoolchain>.<goos>-<goarch>.
-	// If the 0.0.1 indicates anything at all, its the version of the toolchain packaging:
-	// if for some reason we needed to change the way toolchains are packaged into
-	// module zip files in a future version of Go, we could switch to v0.0.2 and then
-	// older versions expecting the old format could use v0.0.1 and newer versions
-	// would use v0.0.2. Of course, then we'd also have to publish two of each
-	// module zip file. It's not likely we'll ever need to change this.
-	gotoolchainModule  = "golang.org/toolchain"
-	gotoolchainVersion = "v0.0.1"
+	"github.com/hyangah/goup/install"
 )
 
 const notice = `
@@ -44,232 +32,717 @@ use different ones.
 
 `
 
-func main() {
-	ctx := context.Background()
-
-	hostOS, hostArch, err := hostOSArch()
-	if err != nil {
-		log.Fatal(err)
-	}
+const usage = `Usage: goup [command] [flags]
 
-	// TODO: quiet mode
+Commands:
+	install      install Go toolchains (the default)
+	bundle       save a verified Go toolchain for offline installation
+	uninstall    remove a Go toolchain installed by goup
+	prune        remove all but the most recent Go toolchains
+	list         list the installed Go toolchains
+	list-remote  list the Go toolchains available for installation
+	use          select the active Go toolchain
+	exec         run a command with a Go toolchain that is not active
+	verify       check the integrity of installed Go toolchains
+	doctor       diagnose common problems with the Go environment
+	cache clean  remove the downloaded toolchain zips kept by goup
+	self-update  update goup to its latest release
+	version      print the version of goup
+	completion   print a shell completion script
 
-	fmt.Printf("Installing Go for %v/%v...\n", hostOS, hostArch)
+Run 'goup <command> -h' for the flags of a command.
+`
 
-	answer := ""
+var (
+	installFlags       = flag.NewFlagSet("goup install", flag.ExitOnError)
+	versionFlag        = installFlags.String("version", "", "Go version to install (e.g. go1.22.3, go1.21rc2, or tip for the latest development build from a -mirror). Defaults to the version in a .go-version file in the current directory or its parents, $GOUP_DEFAULT_VERSION, or the latest release. To install several versions, pass them as arguments instead.")
+	unstableFlag       = installFlags.Bool("unstable", false, "Consider beta and release candidate versions when looking up the latest release.")
+	insecureFlag       = installFlags.Bool("insecure", false, "Skip verifying the downloaded toolchain against the Go checksum database.")
+	checksumDBFlag     = installFlags.String("checksum-db", "", "Checksum `database` to verify the toolchain against, as in GOSUMDB: a known name such as sum.golang.org, or <key> [<url>] for a private one, or off. Defaults to $GOSUMDB, or sum.golang.org.")
+	retriesFlag        = installFlags.Int("retries", 3, "Number of times to retry a failed download.")
+	osFlag             = installFlags.String("os", "", "GOOS of the toolchain to install. Defaults to the host operating system.")
+	archFlag           = installFlags.String("arch", "", "GOARCH of the toolchain to install. Defaults to the host architecture.")
+	platformFlag       = installFlags.String("platform", "", "GOOS/GOARCH of the toolchain to install, e.g. linux/amd64. Shorthand for -os and -arch. Toolchains for other platforms are installed in <dir>/goX.Y.Z.<os>-<arch>.")
+	goarmFlag          = installFlags.String("goarm", "", "ARM variant (5, 6 or 7) of a GOARCH=arm toolchain. Only a flat -mirror can serve variants; elsewhere the GOARM=6 build is installed.")
+	resumeFlag         = installFlags.Bool("resume", false, "Keep a partial extraction if the installation fails, and carry on from one kept before.")
+	skipRunFlag        = installFlags.Bool("skip-verify-run", false, "Do not run the installed go command to check that it works, for machines that can only run it later. Not possible with the bootstrap toolchain used when GOPROXY is empty.")
+	forceFlag          = installFlags.Bool("force", false, "Reinstall the version even if it is already installed.")
+	fromFlag           = installFlags.String("from", "", "Install from a local toolchain `zip` file, or a bundle made by goup bundle, instead of downloading it.")
+	timeoutFlag        = installFlags.Duration("timeout", 10*time.Minute, "Maximum time for each installation, including download and extraction. 0 means no limit.")
+	attemptTimeoutFlag = installFlags.Duration("attempt-timeout", 0, "Maximum time for each download attempt, after which it is retried, within -timeout. 0 means no limit.")
+	updatePathFlag     = installFlags.Bool("update-path", false, "Add the installed go command to PATH in the shell profile.")
+	setGOROOTFlag      = installFlags.Bool("set-goroot", false, "With -update-path, also set GOROOT in the shell profile. Only needed for tools that require GOROOT.")
+	autoFlag           = installFlags.Bool("auto", false, "Install the Go version required by the go.mod file of the current module, as GOTOOLCHAIN=auto would.")
+	checksumFlag       = installFlags.String("checksum", "", "Expected `hash` of the toolchain zip, as sha256:<hex> of the file or its h1: module hash. Checked even with -insecure.")
+	noCacheFlag        = installFlags.Bool("no-cache", false, "Neither use nor fill the cache of downloaded toolchain zips.")
+	mirrorFlag         = installFlags.String("mirror", "", "Base `URL` of a server to download the toolchain from instead of GOPROXY. Defaults to $GOUP_MIRROR.")
+	mirrorLayout       = installFlags.String("mirror-layout", install.MirrorFlat, "Layout of the -mirror: flat, for <mirror>/v0.0.1-<version>.<os>-<arch>.zip, or proxy, for a module proxy.")
+	toolsFlag          = installFlags.String("tools", "", "Comma-separated `module@version` list of tools to go install with the installed toolchain, e.g. golang.org/x/tools/gopls@latest.")
+	postInstallCmd     = installFlags.String("post-install-cmd", "", "Arguments of a go `command` to run with the installed toolchain, e.g. \"install -race std\" to prebuild the race-enabled standard library.")
+	strictTools        = installFlags.Bool("strict-tools", false, "Fail if any of the -tools fails to install.")
+	keepDownloads      = installFlags.Bool("keep-downloads", false, "Keep the downloaded toolchain zip in <dir>/downloads, for use with -from on another machine.")
+	maxRateFlag        = installFlags.String("max-rate", "", "Limit the download speed to this `rate` per second, e.g. 5MB or 500kB. By default, downloads are not limited.")
+	noNoticeFlag       = installFlags.Bool("no-notice", false, "Do not print the notice about the use of the Go module mirror and checksum database, nor ask to continue after it.")
+	dryRunFlag         = installFlags.Bool("dry-run", false, "Print what would be installed and where, without downloading or installing anything.")
+	parallelFlag       = installFlags.Int("parallel", 1, "Number of versions to download and install at once, when installing several. Concurrent installations ask for confirmation once, and print only errors and their results.")
+	quiet              bool
+	dirFlag            string
+	rateLimiter        *install.RateLimiter // from -max-rate, shared by all downloads
+)
 
-	fmt.Print(notice)
-	fmt.Printf("Do you want to continue? (Y/n) ")
-	fmt.Scanf("%s", &answer)
-	if answer != "Y" && answer != "" {
-		fmt.Println("Stopping go installation.")
-		os.Exit(0)
-	}
+func init() {
+	addQuietFlags(installFlags)
+	addDirFlag(installFlags)
+	addColorFlag(installFlags)
+	addJSONFlag(installFlags)
+}
 
-	dst := installDir()
-	fmt.Printf("Go will be installed in %v. Continue? (Y/n) ", dst)
-	fmt.Scanf("%s", &answer)
-	if answer != "Y" && answer != "" {
-		fmt.Println("Stopping go installation.")
-		os.Exit(0)
-	}
+// addDirFlag registers the -dir flag in fs.
+func addDirFlag(fs *flag.FlagSet) {
+	fs.StringVar(&dirFlag, "dir", "", "Root directory of the Go installations. Overrides GOINSTALLDIR.")
+}
 
-	ver := fmt.Sprintf("v0.0.1-go1.21.0beta1-installer.%v-%v", hostOS, hostArch)
-	gobin := filepath.Join(dst, "bin", "go")
-	if _, err := os.Stat(gobin); err != nil {
-		uri := fmt.Sprintf("https://github.com/hyangah/goup/raw/main/res/%v.zip", ver)
-		r, err := ReadZip(ctx, uri)
-		if err != nil {
-			log.Fatal(err)
-		}
-		WriteZip(ctx, dst, r)
-	}
-	// TODO: lookup the latest version and install it.
+// addQuietFlags registers the -quiet flag and its -y alias in fs.
+func addQuietFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&quiet, "quiet", false, "Do not prompt for confirmation and print only errors and the final result.")
+	fs.BoolVar(&quiet, "y", false, "Alias for -quiet.")
+}
 
-	goCommand(gobin, "toolchain", "use", "go1.20.2")
-	fmt.Println()
-	goCommand(gobin, "version")
-	fmt.Println()
-	fmt.Printf("Go is installed in %v successfully.\n", gobin)
-	if p, err := exec.LookPath("go"); err != nil || p != gobin {
-		fmt.Printf("Please ensure %v is in your PATH.\n", filepath.Dir(gobin))
+// logf prints progress messages unless quiet mode is on.
+func logf(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(out, format, args...)
 	}
 }
 
-func hostOSArch() (host, arch string, _ error) {
-	// TODO: handle incorrect GOARCH mode (https://github.com/go-delve/delve/blob/a61ccea65a14a1640e04847e6ce11fbc8b7a0178/pkg/proc/macutil/rosetta_darwin.go#L10)
-	return runtime.GOOS, runtime.GOARCH, nil
-}
-func installDir() string {
-	if dst := os.Getenv("GOINSTALLDIR"); dst != "" {
-		return dst
+// goup exits with status 0 on success, 1 if the command failed, and 2
+// if it was used incorrectly. goup exec exits with the status of the
+// command it ran.
+func main() {
+	// Interrupting goup cancels ctx, which aborts any download in
+	// progress and lets the install clean up after itself.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:])
+	stop()
+	var (
+		uerr   usageError
+		status exitStatus
+	)
+	switch {
+	case err == nil:
+	case errors.As(err, &status):
+		os.Exit(int(status))
+	case errors.As(err, &uerr):
+		fmt.Fprintf(os.Stderr, "goup: %v\n\n%s", err, usage)
+		os.Exit(2)
+	default:
+		reportError(err)
+		os.Exit(1)
 	}
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".go")
 }
 
-func readBody(ctx context.Context, u string) ([]byte, error) {
-	var data []byte
-	err := executeRequest(ctx, u, func(body io.Reader) error {
-		var err error
-		data, err = io.ReadAll(body)
+// A usageError reports that goup was invoked incorrectly.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// run runs the goup command line args.
+func run(ctx context.Context, args []string) error {
+	if install.Version == "devel" {
+		// Not a release build, but go install records the version.
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			install.Version = info.Main.Version
+		}
+	}
+	if err := initHTTPClient(); err != nil {
 		return err
-	})
-	if err != nil {
-		return nil, err
 	}
-	return data, nil
+
+	cmd := "install"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "install":
+		return runInstall(ctx, args)
+	case "bundle":
+		return runBundle(ctx, args)
+	case "uninstall":
+		return runUninstall(ctx, args)
+	case "prune":
+		return runPrune(ctx, args)
+	case "list":
+		return runList(ctx, args)
+	case "list-remote":
+		return runListRemote(ctx, args)
+	case "use":
+		return runUse(ctx, args)
+	case "exec":
+		return runExec(ctx, args)
+	case "verify":
+		return runVerify(ctx, args)
+	case "doctor":
+		return runDoctor(ctx, args)
+	case "cache":
+		return runCache(ctx, args)
+	case "self-update":
+		return runSelfUpdate(ctx, args)
+	case "version":
+		return runVersion(ctx, args)
+	case "completion":
+		return runCompletion(ctx, args)
+	case "__complete":
+		return runComplete(ctx, args)
+	case "help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return usageError(fmt.Sprintf("unknown command %q", cmd))
+	}
 }
 
-// executeRequest executes an HTTP GET request for u, then calls the bodyFunc
-// on the response body, if no error occurred.
-func executeRequest(ctx context.Context, u string, bodyFunc func(body io.Reader) error) (err error) {
-	req, err := http.NewRequest("GET", u, nil)
+// runInstall implements the install command. The versions to install
+// are given by the -version flag, or as arguments to install several.
+func runInstall(ctx context.Context, args []string) error {
+	installFlags.Parse(args)
+	setupOutput()
+
+	versions := installFlags.Args()
+	if *versionFlag != "" {
+		if len(versions) > 0 {
+			return usageError("-version cannot be combined with version arguments")
+		}
+		versions = []string{*versionFlag}
+	}
+	seen := make(map[string]bool)
+	for i, v := range versions {
+		if v == "tip" {
+			v = install.Tip
+			versions[i] = v
+		}
+		if err := install.ValidateVersion(v); err != nil {
+			return err
+		}
+		if seen[v] {
+			return usageError(fmt.Sprintf("%v is listed more than once", v))
+		}
+		seen[v] = true
+	}
+	if *autoFlag {
+		if len(versions) > 0 {
+			return usageError("-auto cannot be combined with -version or version arguments")
+		}
+		gomod, err := install.FindGoMod(".")
+		if err != nil {
+			return err
+		}
+		version, err := install.GoModVersion(gomod)
+		if err != nil {
+			return err
+		}
+		logf("%v requires %v.\n", gomod, version)
+		versions = []string{version}
+	}
+	if len(versions) > 1 && (*fromFlag != "" || *checksumFlag != "" || *toolsFlag != "" || *postInstallCmd != "") {
+		return usageError("-from, -checksum, -tools and -post-install-cmd apply to a single version")
+	}
+	if *parallelFlag < 1 {
+		return usageError("-parallel must be at least 1")
+	}
+	if *postInstallCmd != "" && strings.TrimSpace(*postInstallCmd) == "" {
+		return usageError("-post-install-cmd is empty")
+	}
+	tools, err := parseTools(*toolsFlag)
 	if err != nil {
+		return usageError(err.Error())
+	}
+	if *maxRateFlag != "" {
+		maxRate, err := parseBytes(*maxRateFlag)
+		if err != nil || maxRate == 0 {
+			return usageError(fmt.Sprintf("invalid -max-rate %q: want a rate such as 5MB", *maxRateFlag))
+		}
+		rateLimiter = install.NewRateLimiter(maxRate)
+	}
+	// Without a version given explicitly, with -auto or by the -from zip,
+	// a .go-version file pins it, else GOUP_DEFAULT_VERSION.
+	var pinned string
+	if len(versions) == 0 && *fromFlag == "" {
+		file, err := install.FindGoVersionFile(".")
+		if err != nil {
+			return err
+		}
+		if file != "" {
+			if pinned, err = install.GoVersionFileVersion(file); err != nil {
+				return err
+			}
+			logf("%v pins %v.\n", file, pinned)
+		}
+	}
+	switch {
+	case len(versions) > 0 || *fromFlag != "":
+	case pinned != "":
+		versions = []string{pinned}
+	case os.Getenv("GOUP_DEFAULT_VERSION") != "":
+		v := os.Getenv("GOUP_DEFAULT_VERSION")
+		if err := install.ValidateVersion(v); err != nil {
+			return fmt.Errorf("GOUP_DEFAULT_VERSION: %v", err)
+		}
+		logf("Using GOUP_DEFAULT_VERSION=%v.\n", v)
+		versions = []string{v}
+	default:
+		logf("Neither -version, .go-version nor GOUP_DEFAULT_VERSION is set; installing the latest release.\n")
+	}
+	if len(versions) == 0 {
+		versions = []string{""} // the latest release
+	}
+	if *osFlag, *archFlag, err = parsePlatform(*osFlag, *archFlag, *platformFlag); err != nil {
 		return err
 	}
-	r, err := ctxhttp.Do(ctx, nil, req)
-	if err != nil {
-		return fmt.Errorf("ctxhttp.Do(ctx, client, %q): %v", u, err)
+	if _, arch, _ := install.HostOSArch(); *archFlag == "" && arch != runtime.GOARCH {
+		warnf("goup is running under Rosetta translation; installing the native darwin/arm64 toolchain. Use -arch=amd64 to override.\n")
 	}
-	defer r.Body.Close()
-	if err := responseError(r, false); err != nil {
+
+	if len(versions) == 1 {
+		result, err := installVersion(ctx, versions[0], true)
+		if err != nil || result == nil {
+			return err
+		}
+		// Tools can only be built with a toolchain that runs here.
+		goos, goarch, _ := install.HostOSArch()
+		runs := !result.DryRun && !*skipRunFlag && result.GOOS == goos && result.GOARCH == goarch
+		if runs && len(tools) > 0 {
+			result.Tools, err = installTools(ctx, result.GoBin, tools)
+			if !*strictTools {
+				if err != nil {
+					warnf("%v\n", err)
+				}
+				err = nil
+			}
+		}
+		if runs && err == nil && *postInstallCmd != "" {
+			result.PostInstall, err = runPostInstall(ctx, result.GoBin, *postInstallCmd)
+		}
+		if jsonOutput {
+			if werr := writeJSON(result); werr != nil {
+				return werr
+			}
+			if err != nil {
+				return alreadyReported{err}
+			}
+		}
 		return err
 	}
-	return bodyFunc(r.Body)
-}
 
-// responseError translates the response status code to an appropriate error.
-func responseError(r *http.Response, fetchDisabled bool) error {
-	switch {
-	case 200 <= r.StatusCode && r.StatusCode < 300:
-		return nil
-	case 500 <= r.StatusCode:
-		return fmt.Errorf("internal server error")
-	case r.StatusCode == http.StatusNotFound,
-		r.StatusCode == http.StatusGone:
-		data, err := io.ReadAll(r.Body)
+	// Install the versions, -parallel of them at a time, carrying on
+	// after a failure, and report which of them failed at the end.
+	if *parallelFlag > 1 && !*dryRunFlag {
+		// Concurrent installations cannot take turns asking questions
+		// and drawing progress bars, so ask once and install quietly.
+		root, err := installDir()
 		if err != nil {
-			return fmt.Errorf("io.ReadAll: %v", err)
+			return err
+		}
+		if needsDownload(ctx, versions) && !confirmDownload() || !quiet && !promptYesNo(fmt.Sprintf("Go %v will be installed in %v. Continue?", strings.Join(versions, ", "), root), true) {
+			fmt.Fprintln(out, "Stopping go installation.")
+			return nil
 		}
-		d := string(data)
+		quiet = true
+	}
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, *parallelFlag)
+		ress = make([]*installResult, len(versions))
+		errs = make([]error, len(versions))
+	)
+	for i, v := range versions {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, v string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ress[i], errs[i] = installVersion(ctx, v, false)
+			if errs[i] != nil && ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "goup: %v: %v\n", v, errs[i])
+			}
+		}(i, v)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return installError(ctx, err) // interrupted
+	}
+	var (
+		results           []*installResult
+		succeeded, failed []string
+	)
+	for i, v := range versions {
 		switch {
-		case strings.Contains(d, "fetch timed out"):
-			err = fmt.Errorf("timeout")
-		case fetchDisabled:
-			err = fmt.Errorf("not fetched")
-		default:
-			err = fmt.Errorf("not found")
+		case errs[i] != nil:
+			failed = append(failed, v)
+			results = append(results, &installResult{Version: v, Error: errs[i].Error()})
+		case ress[i] != nil:
+			succeeded = append(succeeded, v)
+			results = append(results, ress[i])
 		}
-		return fmt.Errorf("%q: %w", d, err)
-	default:
-		return fmt.Errorf("unexpected status %d %s", r.StatusCode, r.Status)
 	}
+	err = nil
+	if len(failed) > 0 {
+		err = fmt.Errorf("%d of %d installations failed: %v", len(failed), len(versions), strings.Join(failed, ", "))
+	}
+	if jsonOutput {
+		if werr := writeJSON(results); werr != nil {
+			return werr
+		}
+		if err != nil {
+			return alreadyReported{err}
+		}
+		return nil
+	}
+	if len(succeeded) > 0 {
+		fmt.Printf("Installed %v.\n", strings.Join(succeeded, ", "))
+	}
+	return err
 }
 
-func ReadZip(ctx context.Context, u string) (*zip.Reader, error) {
-	bodyBytes, err := readBody(ctx, u)
-	if err != nil {
-		return nil, err
+// parsePlatform returns the GOOS and GOARCH given by the -os, -arch and
+// -platform flags of a command, "" for those not given.
+func parsePlatform(goos, goarch, platform string) (string, string, error) {
+	if platform == "" {
+		return goos, goarch, nil
 	}
-	zipReader, err := zip.NewReader(bytes.NewReader(bodyBytes), int64(len(bodyBytes)))
-	if err != nil {
-		return nil, err
+	pos, parch, ok := strings.Cut(platform, "/")
+	if !ok || pos == "" || parch == "" || strings.Contains(parch, "/") {
+		return "", "", usageError(fmt.Sprintf("invalid -platform %q: want GOOS/GOARCH, e.g. linux/amd64", platform))
 	}
-	return zipReader, nil
+	if goos != "" && goos != pos || goarch != "" && goarch != parch {
+		return "", "", usageError(fmt.Sprintf("-platform %v conflicts with -os and -arch", platform))
+	}
+	if err := install.ValidatePlatform(pos, parch); err != nil {
+		return "", "", err
+	}
+	return pos, parch, nil
 }
 
-func WriteZip(ctx context.Context, dst string, archive *zip.Reader) {
-	_ = os.MkdirAll(dst, os.ModeDir|os.ModePerm)
-	for _, f := range archive.File {
-		filePath := filepath.Join(dst, f.Name)
+// installVersion installs version, or the latest release if it is
+// empty, as configured by the install flags. It returns nil if the user
+// declined the installation. Unless pathSetup is false, it explains how
+// to add the installed go command to PATH if needed.
+func installVersion(ctx context.Context, version string, pathSetup bool) (*installResult, error) {
+	if *timeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+		defer cancel()
+	}
 
-		if !strings.HasPrefix(filePath, filepath.Clean(dst)+string(os.PathSeparator)) {
-			fmt.Println("invalid file path")
-			return
-		}
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(filePath, os.ModePerm)
-			continue
-		}
+	plan, err := newPlan(ctx, version)
+	if err != nil {
+		return nil, installError(ctx, err)
+	}
 
-		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
-			panic(err)
-		}
+	if *dryRunFlag {
+		url := printDryRun(ctx, plan)
+		return &installResult{Success: url != "", DryRun: true, Version: plan.Version, GOOS: plan.GOOS, GOARCH: plan.GOARCH, Dir: plan.Dir}, nil
+	}
+	logf("Installing %v for %v/%v...\n", plan.Version, plan.GOOS, plan.GOARCH)
+	switch {
+	case plan.FromBundle():
+		logf("Installing from the bundle %v, verified when the bundle was made.\n", *fromFlag)
+	case plan.Version == install.Tip:
+		logf("Tip builds are not in the checksum database, so the toolchain is not verified.\n")
+	case plan.NoSumDB && !*insecureFlag:
+		logf("Not verifying the toolchain against the checksum database, as configured by -checksum-db, GOSUMDB, GONOSUMDB or GOPRIVATE.\n")
+	}
 
-		dstFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			panic(err)
+	result := &installResult{Success: true, Version: plan.Version, GOOS: plan.GOOS, GOARCH: plan.GOARCH, Dir: plan.Dir, GoBin: install.GoBinary(plan.Dir)}
+	if !*forceFlag && plan.Installed() {
+		if !jsonOutput {
+			fmt.Printf("%v already installed in %v.\n", plan.Version, plan.Dir)
+			if plan.Version == install.Tip {
+				fmt.Println("Use -force to install the latest tip build.")
+			}
 		}
+		return result, nil
+	}
 
-		fileInArchive, err := f.Open()
+	if plan.Downloads() && !confirmDownload() {
+		fmt.Fprintln(out, "Stopping go installation.")
+		return nil, nil
+	}
+	if !quiet && !promptYesNo(fmt.Sprintf("Go will be installed in %v. Continue?", plan.Dir), true) {
+		fmt.Fprintln(out, "Stopping go installation.")
+		return nil, nil
+	}
+
+	res, err := plan.Run(ctx)
+	if err != nil {
+		return nil, installError(ctx, err)
+	}
+	// Run tells which build the source had.
+	if *goarmFlag != "" && plan.GOARM == "" {
+		logf("The download source has a single arm build, for GOARM=6, which runs on ARMv6 and later.\n")
+	}
+	if !plan.Cross && plan.Libc == "" && install.HostLibc() == "musl" {
+		warnf("this machine uses the musl C library, but the download source only has the standard Go build, linked against glibc, which may not run here. Install a glibc compatibility package, such as gcompat on Alpine, or use a -mirror that serves musl builds.\n")
+	}
+	if res.Zip != "" {
+		result.Zip = res.Zip
+		logf("Kept the toolchain zip as %v.\n", res.Zip)
+	}
+	if plan.Cross {
+		if !jsonOutput {
+			fmt.Println(colorize(os.Stdout, green, fmt.Sprintf("Go for %v/%v is installed in %v successfully.", res.GOOS, res.GOARCH, res.Dir)))
+		}
+		return result, nil
+	}
+	if *skipRunFlag {
+		logf("Not running the installed go command, as -skip-verify-run is set.\n\n")
+	} else {
+		logf("go version %v %v/%v\n\n", res.Version, res.GOOS, res.GOARCH)
+	}
+	result.GOROOT = res.Dir
+	if !jsonOutput {
+		fmt.Println(colorize(os.Stdout, green, fmt.Sprintf("Go is installed in %v successfully.", res.GoBin)))
+	}
+	root, err := installDir()
+	if err != nil {
+		return nil, err
+	}
+	if res.Version == install.Tip {
+		link, err := setTipLink(root, res.Dir)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		logf("Run Go tip as %v.\n", link)
+	}
+	if err := setFirstDefault(root, res.Version); err != nil {
+		return nil, err
+	}
+	if !pathSetup {
+		return result, nil
+	}
+	bindir := filepath.Dir(res.GoBin)
+	other := shadowingGo(res.GoBin)
+	result.ShadowedBy = other
+	switch {
+	case !inPath(bindir):
+		printPathSetup(bindir)
+	case other != "" && !quiet && !jsonOutput:
+		// A go command installed by other means, such as a package
+		// manager, comes earlier in PATH.
+		warnf("%v comes before %v in PATH,\nso the go command is still %v.\nMove %v to the front of PATH, or uninstall the other Go.\n", filepath.Dir(other), bindir, other, bindir)
+	}
+	printGOROOTSetup(res.Dir)
+	return result, nil
+}
 
-		if _, err := io.Copy(dstFile, fileInArchive); err != nil {
-			panic(err)
-		}
+// defaultMu serializes setFirstDefault between concurrent installations.
+var defaultMu sync.Mutex
 
-		dstFile.Close()
-		fileInArchive.Close()
+// setFirstDefault makes version the default under root if there is no
+// default yet, as there is not before the first installation.
+func setFirstDefault(root, version string) error {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if v, err := install.DefaultVersion(root); err != nil || v != "" {
+		return nil
+	}
+	if err := install.SetDefaultVersion(root, version); err != nil {
+		return err
 	}
+	logf("%v is the default version.\n", version)
+	return nil
 }
 
-func setExecutable(gotoolchain, dir string) {
-	// On first use after download, set the execute bits on the commands
-	// so that we can run them. Note that multiple go commands might be
-	// doing this at the same time, but if so no harm done.
-	if runtime.GOOS != "windows" {
-		info, err := os.Stat(filepath.Join(dir, "bin/go"))
-		if err != nil {
-			log.Fatalf("download %s: %v", gotoolchain, err)
+// newPlan returns the plan to install version, or the latest release if
+// it is empty, as configured by the install flags.
+func newPlan(ctx context.Context, version string) (*install.Plan, error) {
+	root, err := installDir()
+	if err != nil {
+		return nil, err
+	}
+	mirror := *mirrorFlag
+	if mirror == "" {
+		mirror = os.Getenv("GOUP_MIRROR")
+	}
+	sumDB := *checksumDBFlag
+	if sumDB == "" {
+		sumDB = os.Getenv("GOSUMDB")
+	}
+	opts := install.Options{
+		Version:        version,
+		Unstable:       *unstableFlag,
+		Dir:            root,
+		GOOS:           *osFlag,
+		GOARCH:         *archFlag,
+		GOARM:          *goarmFlag,
+		Libc:           install.HostLibc(),
+		From:           *fromFlag,
+		GOPROXY:        os.Getenv("GOPROXY"),
+		Mirror:         mirror,
+		MirrorLayout:   *mirrorLayout,
+		Insecure:       *insecureFlag,
+		SumDB:          sumDB,
+		GONOSUMDB:      goNoSumDB(),
+		Checksum:       *checksumFlag,
+		SkipRun:        *skipRunFlag,
+		Force:          *forceFlag,
+		Resume:         *resumeFlag,
+		Retries:        *retriesFlag,
+		AttemptTimeout: *attemptTimeoutFlag,
+		RateLimiter:    rateLimiter,
+		Client:         httpClient,
+	}
+	if !*noCacheFlag {
+		opts.CacheDir = filepath.Join(root, "cache")
+	}
+	if *keepDownloads {
+		opts.KeepDir = filepath.Join(opts.Dir, "downloads")
+	}
+	if showProgress() {
+		opts.Progress = (&progressBar{w: out}).report
+	}
+	if !quiet {
+		opts.Output = out
+	}
+	return install.NewPlan(ctx, opts)
+}
+
+// needsDownload reports whether installing versions downloads any of
+// them, rather than finding them installed or in the cache. A version
+// that cannot be planned is left for its installation to report.
+func needsDownload(ctx context.Context, versions []string) bool {
+	for _, v := range versions {
+		plan, err := newPlan(ctx, v)
+		if err == nil && plan.Downloads() && (*forceFlag || !plan.Installed()) {
+			return true
 		}
-		if info.Mode()&0111 == 0 {
-			// allowExec sets the exec permission bits on all files found in dir.
-			allowExec := func(dir string) {
-				err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-					if err != nil {
-						return err
-					}
-					if !d.IsDir() {
-						info, err := os.Stat(path)
-						if err != nil {
-							return err
-						}
-						if err := os.Chmod(path, info.Mode()&0777|0111); err != nil {
-							return err
-						}
-					}
-					return nil
-				})
-				if err != nil {
-					log.Fatalf("download %s: %v", gotoolchain, err)
-				}
-			}
+	}
+	return false
+}
+
+// The notice is shown before the first download of the run, which
+// asks for confirmation unless quiet. -no-notice skips both.
+var (
+	noticeMu                       sync.Mutex
+	noticeShown, downloadConfirmed bool
+)
+
+// confirmDownload shows the notice if it has not yet been shown, and
+// reports whether the user agreed to continue. Installations running
+// concurrently call it with quiet set.
+func confirmDownload() bool {
+	noticeMu.Lock()
+	defer noticeMu.Unlock()
+	if noticeShown {
+		return downloadConfirmed
+	}
+	noticeShown = true
+	switch {
+	case *noNoticeFlag:
+		// The user has read the notice before.
+		downloadConfirmed = true
+	case quiet:
+		// The notice is still shown, on stderr, so that users are not
+		// surprised by the use of the module mirror.
+		fmt.Fprint(os.Stderr, notice)
+		downloadConfirmed = true
+	default:
+		fmt.Fprint(out, notice)
+		downloadConfirmed = promptYesNo("Do you want to continue?", true)
+	}
+	return downloadConfirmed
+}
+
+// installError returns the error to report for an installation that
+// failed with err, which is clearer if ctx expired because of the
+// -timeout flag, was cancelled by an interrupt, or ran out of retries
+// after download attempts timed out (see -attempt-timeout).
+func installError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && *attemptTimeoutFlag > 0:
+		return fmt.Errorf("installation timed out after %v (see -timeout), with each download attempt limited to %v by -attempt-timeout", *timeoutFlag, *attemptTimeoutFlag)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("installation timed out after %v (see -timeout)", *timeoutFlag)
+	case ctx.Err() != nil:
+		return errors.New("installation cancelled")
+	case errors.Is(err, install.ErrAttemptTimeout):
+		return fmt.Errorf("%v (see -attempt-timeout, -retries and -timeout)", err)
+	}
+	return err
+}
 
-			// Set the bits in pkg/tool before bin/go.
-			// If we are racing with another go command and do bin/go first,
-			// then the check of bin/go above might succeed, the other go command
-			// would skip its own mode-setting, and then the go command might
-			// try to run a tool before we get to setting the bits on pkg/tool.
-			// Setting pkg/tool before bin/go avoids that ordering problem.
-			// The only other tool the go command invokes is gofmt,
-			// so we set that one explicitly before handling bin (which will include bin/go).
-			allowExec(filepath.Join(dir, "pkg/tool"))
-			allowExec(filepath.Join(dir, "bin/gofmt"))
-			allowExec(filepath.Join(dir, "bin"))
+// goNoSumDB returns the go command's GONOSUMDB setting, which defaults
+// to GOPRIVATE.
+func goNoSumDB() string {
+	if v := os.Getenv("GONOSUMDB"); v != "" {
+		return v
+	}
+	return os.Getenv("GOPRIVATE")
+}
+
+// installDir returns the root directory under which toolchains are installed.
+// The -dir flag takes precedence over the GOINSTALLDIR environment variable,
+// which takes precedence over defaultInstallDir.
+func installDir() (string, error) {
+	if dirFlag != "" {
+		if env := os.Getenv("GOINSTALLDIR"); env != "" {
+			logf("Using -dir=%v instead of GOINSTALLDIR=%v.\n", dirFlag, env)
 		}
+		return expandPath(dirFlag), nil
+	}
+	if dst := os.Getenv("GOINSTALLDIR"); dst != "" {
+		return dst, nil
 	}
+	return defaultInstallDir()
 }
 
-func goCommand(bin string, args ...string) {
-	c := exec.Command(bin, args...)
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	err := c.Run()
+// defaultInstallDir returns the platform's conventional directory for
+// application data: %LOCALAPPDATA%\goup on Windows,
+// ~/Library/Application Support/goup on macOS, and $XDG_DATA_HOME/goup,
+// or ~/.local/share/goup, elsewhere. Older versions of goup installed
+// into ~/.go, which is still used if it exists. It returns an error if
+// the directory is in the home directory, which cannot be determined.
+func defaultInstallDir() (string, error) {
+	home, homeErr := os.UserHomeDir()
+	if homeErr == nil {
+		legacy := filepath.Join(home, ".go")
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy, nil
+		}
+	}
+	var dir string
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "goup"), nil
+		}
+		dir = filepath.Join(home, "AppData", "Local", "goup")
+	case "darwin", "ios":
+		dir = filepath.Join(home, "Library", "Application Support", "goup")
+	default:
+		if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, "goup"), nil
+		}
+		dir = filepath.Join(home, ".local", "share", "goup")
+	}
+	if homeErr != nil {
+		return "", fmt.Errorf("cannot choose the install directory: %v; set GOINSTALLDIR or use -dir", homeErr)
+	}
+	return dir, nil
+}
 
-	if err != nil {
-		panic(err)
+// expandPath expands a leading ~ in path to the user's home directory
+// and makes the result absolute.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
 	}
+	return path
 }
@@ -0,0 +1,80 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// out receives the human-readable output. With -json, it is stderr,
+// so that stdout only carries the JSON result.
+var out io.Writer = os.Stdout
+
+// jsonOutput is set by the -json flag.
+var jsonOutput bool
+
+// addJSONFlag registers the -json flag in fs.
+func addJSONFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&jsonOutput, "json", false, "Print the result as a JSON object on stdout, and all other output on stderr.")
+}
+
+// setupOutput redirects human-readable output to stderr if -json is set.
+// It must be called after the flags are parsed.
+func setupOutput() {
+	if jsonOutput {
+		out = os.Stderr
+	}
+}
+
+// installResult is the JSON output of the install command.
+type installResult struct {
+	Success bool   `json:"success"`
+	DryRun  bool   `json:"dryRun,omitempty"`
+	Version string `json:"version,omitempty"`
+	GOOS    string `json:"goos,omitempty"`
+	GOARCH  string `json:"goarch,omitempty"`
+	Dir     string `json:"dir,omitempty"`    // the installed GOROOT
+	GoBin   string `json:"gobin,omitempty"`  // the installed go command
+	GOROOT  string `json:"goroot,omitempty"` // GOROOT to set for tools that need it
+	Zip     string `json:"zip,omitempty"`    // the toolchain zip kept with -keep-downloads
+	// ShadowedBy is the go command found in PATH before GoBin, if any.
+	ShadowedBy string `json:"shadowedBy,omitempty"`
+	// Tools are the results of installing the -tools.
+	Tools []toolResult `json:"tools,omitempty"`
+	// PostInstall is the result of the -post-install-cmd.
+	PostInstall *postInstallResult `json:"postInstall,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// writeJSON prints v as indented JSON on stdout.
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// An alreadyReported error is one that a command has already included
+// in its JSON output.
+type alreadyReported struct{ error }
+
+// reportError prints the error a command failed with on stderr, or with
+// -json, as a JSON object on stdout.
+func reportError(err error) {
+	if jsonOutput {
+		if errors.As(err, new(alreadyReported)) {
+			return
+		}
+		if writeJSON(installResult{Error: err.Error()}) == nil {
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, colorize(os.Stderr, red, fmt.Sprintf("goup: %v", err)))
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hyangah/goup/install"
+)
+
+// progressBar reports the progress of each stage of an installation
+// (see install.Options.Progress) on a single, repeatedly overwritten
+// line of w.
+type progressBar struct {
+	w    io.Writer
+	last time.Time
+}
+
+func (p *progressBar) report(stage string, done, total int64) {
+	finished := done == total
+	if !finished && time.Since(p.last) < 100*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	label := "Downloading..."
+	if stage == install.StageExtract {
+		label = "Extracting... "
+	}
+	if total > 0 {
+		fmt.Fprintf(p.w, "\r%s %3d%% (%s / %s)", label, done*100/total, formatBytes(done), formatBytes(total))
+	} else {
+		fmt.Fprintf(p.w, "\r%s %s", label, formatBytes(done))
+	}
+	if finished {
+		// Terminate the progress line.
+		fmt.Fprintln(p.w)
+	}
+}
+
+// formatBytes formats n as a human-readable size such as "12.3 MB".
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// parseBytes parses a size such as "5MB", "500k" or "1.5 MiB", or a
+// rate such as "5MB/s", using the decimal units of formatBytes unless
+// the binary ones are spelled out. A plain number is a number of bytes.
+func parseBytes(s string) (int64, error) {
+	t := strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	num := strings.TrimRightFunc(t, unicode.IsLetter)
+	unit := strings.ToLower(t[len(num):])
+	mult := float64(1)
+	const units = "kmgt"
+	switch {
+	case unit == "" || unit == "b":
+	case len(unit) <= 2 && strings.Contains(units, unit[:1]) && strings.TrimPrefix(unit[1:], "b") == "":
+		mult = math.Pow(1000, float64(strings.Index(units, unit[:1])+1))
+	case len(unit) == 3 && strings.Contains(units, unit[:1]) && unit[1:] == "ib":
+		mult = math.Pow(1024, float64(strings.Index(units, unit[:1])+1))
+	default:
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, t[len(num):])
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	// An int64 holds less than 2^63, the float64 nearest MaxInt64.
+	if v := n * mult; err != nil || math.IsNaN(v) || v < 0 || v >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * mult), nil
+}
+
+// showProgress reports whether a progress bar should be displayed,
+// that is, when quiet mode is off and the output is a terminal.
+func showProgress() bool {
+	return !quiet && isTerminal(out)
+}
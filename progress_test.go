@@ -0,0 +1,44 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+	}{
+		{"500", 500},
+		{"500B", 500},
+		{"5MB", 5_000_000},
+		{"5mb/s", 5_000_000},
+		{"1.5k", 1500},
+		{"2KiB", 2048},
+		{"1GiB", 1 << 30},
+		{"8000000TB", 8_000_000_000_000_000_000},
+	} {
+		if got, err := parseBytes(tc.in); err != nil || got != tc.want {
+			t.Errorf("parseBytes(%q) = %d, %v; want %d", tc.in, got, err, tc.want)
+		}
+	}
+	for _, in := range []string{
+		"",
+		"-5MB",
+		"5XB",
+		"NaN",
+		"NaNMB",
+		"Inf",
+		"+InfB",
+		"1e400",
+		"0x1p70",
+		"9300000TB",
+		"9.3e18",
+	} {
+		if got, err := parseBytes(in); err == nil {
+			t.Errorf("parseBytes(%q) = %d, want an error", in, got)
+		}
+	}
+}
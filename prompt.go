@@ -0,0 +1,59 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdin is shared by all prompts so that no buffered input is lost
+// between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptYesNo prints question and reads the user's answer from stdin.
+// An empty answer selects the default given by defaultYes; otherwise
+// y, yes, n and no are accepted in any case, and the question is asked
+// again for any other answer. Answers are read one line at a time, so
+// that several prompts can be answered by piping as many lines to goup.
+// The end of stdin answers no, whatever the default: nobody is there to
+// agree.
+func promptYesNo(question string, defaultYes bool) bool {
+	return ask(stdin, out, question, defaultYes)
+}
+
+// ask is promptYesNo, reading the answer from r and writing to w. If
+// r is not a *bufio.Reader, what it buffers beyond the answer is lost;
+// successive prompts should share one.
+func ask(r io.Reader, w io.Writer, question string, defaultYes bool) bool {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	choices := "y/N"
+	if defaultYes {
+		choices = "Y/n"
+	}
+	for {
+		fmt.Fprintf(w, "%s (%s) ", question, choices)
+		line, err := br.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(w)
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return defaultYes
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		fmt.Fprintln(w, "Please answer yes or no.")
+	}
+}
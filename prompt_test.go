@@ -0,0 +1,56 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAsk(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		input      string
+		defaultYes bool
+		want       []bool // answers to as many questions in a row
+		rest       string // input left unread
+	}{
+		{name: "yes", input: "y\n", want: []bool{true}},
+		{name: "no", input: "no\n", defaultYes: true, want: []bool{false}},
+		{name: "case", input: "YES\nN\n", want: []bool{true, false}},
+		{name: "default yes", input: "\n", defaultYes: true, want: []bool{true}},
+		{name: "default no", input: "  \n", want: []bool{false}},
+		{name: "asks again", input: "maybe\ny\n", want: []bool{true}},
+		{name: "one line each", input: "Y\nY\nrest\n", want: []bool{true, true}, rest: "rest\n"},
+		{name: "no newline", input: "y", want: []bool{true}},
+		{name: "EOF", input: "", defaultYes: true, want: []bool{false}},
+		{name: "EOF after answers", input: "y\n", defaultYes: true, want: []bool{true, false, false}},
+		{name: "EOF after a wrong answer", input: "maybe\n", defaultYes: true, want: []bool{false}},
+		{name: "CRLF", input: "y\r\nn\r\n", want: []bool{true, false}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tc.input))
+			for i, want := range tc.want {
+				if got := ask(r, io.Discard, "Continue?", tc.defaultYes); got != want {
+					t.Errorf("answer %d = %v, want %v", i, got, want)
+				}
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != tc.rest {
+				t.Errorf("left %q unread, want %q", rest, tc.rest)
+			}
+		})
+	}
+}
+
+func TestAskOutput(t *testing.T) {
+	var w strings.Builder
+	ask(strings.NewReader("what\nn\n"), &w, "Remove go1.21.0?", false)
+	const want = "Remove go1.21.0? (y/N) Please answer yes or no.\nRemove go1.21.0? (y/N) "
+	if w.String() != want {
+		t.Errorf("ask wrote %q, want %q", w.String(), want)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyangah/goup/install"
+)
+
+var (
+	pruneFlags  = flag.NewFlagSet("goup prune", flag.ExitOnError)
+	pruneKeep   = pruneFlags.Int("keep", 2, "Number of the most recent versions to keep, besides the active and the default one.")
+	pruneDryRun = pruneFlags.Bool("dry-run", false, "List the toolchains that would be removed, without removing them.")
+)
+
+func init() {
+	addQuietFlags(pruneFlags)
+	addColorFlag(pruneFlags)
+	addDirFlag(pruneFlags)
+}
+
+// runPrune implements the prune command, which removes all but the most
+// recent toolchains installed by goup. The active and the default
+// version are always kept, and so is tip, which is not older or newer
+// than any release; remove it with goup uninstall tip. Toolchains for
+// other platforms are pruned separately, keeping as many of each.
+func runPrune(ctx context.Context, args []string) error {
+	pruneFlags.Parse(args)
+	if *pruneKeep < 0 {
+		return usageError("-keep must not be negative")
+	}
+
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+	installs, err := install.InstalledVersions(root)
+	if err != nil {
+		return err
+	}
+	var managed []install.Installation
+	for _, in := range installs {
+		if in.Version != install.Tip && install.IsManaged(in.Dir) {
+			managed = append(managed, in)
+		}
+	}
+	sort.SliceStable(managed, func(i, j int) bool {
+		return install.CompareVersions(managed[i].Version, managed[j].Version) > 0
+	})
+	var dirs []string
+	kept := make(map[string]int) // by platform, "/" for the host
+	for _, in := range managed {
+		platform := in.GOOS + "/" + in.GOARCH
+		if kept[platform] >= *pruneKeep && !in.Active && !in.Default {
+			dirs = append(dirs, in.Dir)
+			continue
+		}
+		kept[platform]++
+	}
+	if len(dirs) == 0 {
+		fmt.Println("Nothing to prune.")
+		return nil
+	}
+
+	if *pruneDryRun {
+		var total int64
+		for _, dir := range dirs {
+			size, err := dirSize(dir)
+			if err != nil {
+				return err
+			}
+			total += size
+			fmt.Printf("Would remove %v (%s).\n", dir, formatBytes(size))
+		}
+		fmt.Printf("%s would be freed.\n", formatBytes(total))
+		return nil
+	}
+	if !quiet && !promptYesNo(fmt.Sprintf("Remove %v?", strings.Join(dirs, ", ")), false) {
+		fmt.Println("Stopping go pruning.")
+		return nil
+	}
+	return removeInstallations(root, dirs)
+}
@@ -0,0 +1,231 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hyangah/goup/install"
+	"golang.org/x/mod/semver"
+)
+
+var selfUpdateFlags = flag.NewFlagSet("goup self-update", flag.ExitOnError)
+
+func init() {
+	addQuietFlags(selfUpdateFlags)
+	addColorFlag(selfUpdateFlags)
+}
+
+// latestReleaseURL describes the latest release of goup.
+// See https://docs.github.com/en/rest/releases/releases#get-the-latest-release.
+const latestReleaseURL = "https://api.github.com/repos/hyangah/goup/releases/latest"
+
+// checksumsAsset is the release asset listing the SHA-256 of the other
+// assets, one "<hex>  <name>" line each, as written by sha256sum.
+const checksumsAsset = "checksums.txt"
+
+// Limits on what self-update downloads: the release description and
+// checksums are small, and a goup archive a few megabytes.
+const (
+	selfUpdateRetries  = 3
+	maxReleaseInfoSize = 1 << 20
+	maxArchiveSize     = 64 << 20
+)
+
+// releaseArchive returns the name of the release asset holding goup for
+// the host, as named by the archives section of .goreleaser.yaml; the
+// version in it is the tag without its "v".
+func releaseArchive(tag string) string {
+	return fmt.Sprintf("goup_%v_%v_%v.zip", strings.TrimPrefix(tag, "v"), runtime.GOOS, runtime.GOARCH)
+}
+
+// githubRelease is the part of a GitHub release that goup uses.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the asset called name.
+func (r *githubRelease) assetURL(name string) (string, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %v has no %v", r.TagName, name)
+}
+
+// runSelfUpdate implements the self-update command, which replaces the
+// running goup binary with the one from the latest release.
+func runSelfUpdate(ctx context.Context, args []string) error {
+	selfUpdateFlags.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	data, err := install.Get(ctx, httpClient, latestReleaseURL, selfUpdateRetries, maxReleaseInfoSize)
+	if err != nil {
+		return fmt.Errorf("looking up the latest goup release: %v", err)
+	}
+	var rel githubRelease
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return fmt.Errorf("parsing %v: %v", latestReleaseURL, err)
+	}
+	if !semver.IsValid(rel.TagName) {
+		return fmt.Errorf("latest goup release has an invalid version %q", rel.TagName)
+	}
+	// A development build, whose version is not a release tag, sorts
+	// before all releases.
+	current := install.Version
+	if semver.Compare(current, rel.TagName) >= 0 {
+		fmt.Printf("goup is up to date (%v; the latest release is %v).\n", current, rel.TagName)
+		return nil
+	}
+
+	name := releaseArchive(rel.TagName)
+	archiveURL, err := rel.assetURL(name)
+	if err != nil {
+		return err
+	}
+	sumsURL, err := rel.assetURL(checksumsAsset)
+	if err != nil {
+		return err
+	}
+	if !quiet && !promptYesNo(fmt.Sprintf("Update %v from %v to %v?", exe, current, rel.TagName), true) {
+		fmt.Println("Stopping goup update.")
+		return nil
+	}
+
+	sums, err := install.Get(ctx, httpClient, sumsURL, selfUpdateRetries, maxReleaseInfoSize)
+	if err != nil {
+		return err
+	}
+	want, err := assetChecksum(sums, name)
+	if err != nil {
+		return fmt.Errorf("%v: %v", sumsURL, err)
+	}
+	archive, err := install.Get(ctx, httpClient, archiveURL, selfUpdateRetries, maxArchiveSize)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(archive)
+	if got := hex.EncodeToString(h[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %v: downloaded %v, %v lists %v", name, got, checksumsAsset, want)
+	}
+	bin, err := archiveBinary(archive)
+	if err != nil {
+		return fmt.Errorf("%v: %v", name, err)
+	}
+	if err := replaceExecutable(exe, bin); err != nil {
+		return err
+	}
+	fmt.Printf("Updated goup to %v.\n", rel.TagName)
+	return nil
+}
+
+// assetChecksum returns the SHA-256 listed for name in sums, the
+// content of checksumsAsset.
+func assetChecksum(sums []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		sum, file, ok := strings.Cut(sc.Text(), "  ")
+		if ok && strings.TrimPrefix(file, "*") == name {
+			return strings.ToLower(sum), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %v", name)
+}
+
+// replaceExecutable atomically replaces the executable at exe with bin:
+// it is written to a temporary file in the same directory, which is
+// then renamed over exe. Windows does not allow replacing a running
+// executable, but does allow renaming it, so there it is moved aside
+// first and left to be removed by the next update.
+func replaceExecutable(exe string, bin []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".goup-update-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old) // left behind by a previous update
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(old, exe)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmp.Name(), exe)
+}
+
+// archiveBinary returns the goup binary in archive, a release archive.
+func archiveBinary(archive []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+	name := "goup"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		if f.UncompressedSize64 > maxArchiveSize {
+			return nil, fmt.Errorf("%v is too large: %d bytes", name, f.UncompressedSize64)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		// The reader fails if the entry holds more than it declares.
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("archive has no %v", name)
+}
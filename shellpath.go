@@ -0,0 +1,161 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// shellConfig describes how to add a directory to PATH for a shell.
+type shellConfig struct {
+	name    string
+	profile string // profile file, relative to the home directory
+	format  string // a line that adds %[1]s to PATH
+	goroot  string // a line that sets GOROOT to %[1]s
+}
+
+var shellConfigs = map[string]shellConfig{
+	"bash": {"bash", ".bashrc", `export PATH="%[1]s:$PATH"`, `export GOROOT="%[1]s"`},
+	"zsh":  {"zsh", ".zshrc", `export PATH="%[1]s:$PATH"`, `export GOROOT="%[1]s"`},
+	"sh":   {"sh", ".profile", `export PATH="%[1]s:$PATH"`, `export GOROOT="%[1]s"`},
+	"fish": {"fish", ".config/fish/config.fish", `set -gx PATH "%[1]s" $PATH`, `set -gx GOROOT "%[1]s"`},
+	"csh":  {"csh", ".cshrc", `setenv PATH "%[1]s:$PATH"`, `setenv GOROOT "%[1]s"`},
+	"tcsh": {"tcsh", ".tcshrc", `setenv PATH "%[1]s:$PATH"`, `setenv GOROOT "%[1]s"`},
+}
+
+// userShell returns the configuration for the user's shell, according
+// to $SHELL, defaulting to sh.
+func userShell() shellConfig {
+	sh, ok := shellConfigs[filepath.Base(os.Getenv("SHELL"))]
+	if !ok {
+		sh = shellConfigs["sh"]
+	}
+	if sh.name == "bash" && runtime.GOOS == "darwin" {
+		// Terminal windows on macOS start login shells,
+		// which do not read .bashrc.
+		sh.profile = ".bash_profile"
+	}
+	return sh
+}
+
+// pathLine returns the line that adds dir to PATH in sh's syntax.
+func (sh shellConfig) pathLine(dir string) string {
+	return fmt.Sprintf(sh.format, dir)
+}
+
+// updateProfile appends line to the profile of sh, unless the profile
+// already contains it. It returns the path of the profile.
+func (sh shellConfig) updateProfile(line string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	profile := filepath.Join(home, filepath.FromSlash(sh.profile))
+	data, err := os.ReadFile(profile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	for _, l := range bytes.Split(data, []byte("\n")) {
+		if string(bytes.TrimSpace(l)) == line {
+			return profile, nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(profile), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(profile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return "", err
+	}
+	text := "\n# Added by goup.\n" + line + "\n"
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		text = "\n" + text
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	return profile, f.Close()
+}
+
+// printPathSetup tells the user how to add dir to PATH, and with
+// -update-path, adds it to the shell profile.
+func printPathSetup(dir string) {
+	if runtime.GOOS == "windows" {
+		logf("Please ensure %v is in your PATH.\n", dir)
+		return
+	}
+	sh := userShell()
+	line := sh.pathLine(dir)
+	if !*updatePathFlag {
+		logf("%v is not in your PATH. To add it, add this line to ~/%v:\n\n\t%v\n\n", dir, sh.profile, line)
+		return
+	}
+	profile, err := sh.updateProfile(line)
+	if err != nil {
+		warnf("cannot update %v: %v\n", filepath.Join("~", sh.profile), err)
+		return
+	}
+	logf("Added %v to PATH in %v. Restart your shell or run:\n\n\t%v\n\n", dir, profile, line)
+}
+
+// printGOROOTSetup shows how to set GOROOT to dir, for tools that
+// insist on it, and with -update-path -set-goroot, sets it in the shell
+// profile.
+func printGOROOTSetup(dir string) {
+	if runtime.GOOS == "windows" {
+		logf("If a tool requires GOROOT, set it to %v. The go command itself does not need it.\n", dir)
+		return
+	}
+	sh := userShell()
+	line := fmt.Sprintf(sh.goroot, dir)
+	if !*updatePathFlag || !*setGOROOTFlag {
+		logf("The go command finds its GOROOT without help. Only if a tool requires GOROOT to be set, add this line to ~/%v:\n\n\t%v\n\n", sh.profile, line)
+		return
+	}
+	profile, err := sh.updateProfile(line)
+	if err != nil {
+		warnf("cannot update %v: %v\n", filepath.Join("~", sh.profile), err)
+		return
+	}
+	logf("Set GOROOT to %v in %v.\n", dir, profile)
+}
+
+// inPath reports whether dir is one of the directories in PATH.
+func inPath(dir string) bool {
+	for _, d := range filepath.SplitList(os.Getenv("PATH")) {
+		if d != "" && sameFile(d, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowingGo returns the go command that running go in a shell would
+// find in PATH instead of gobin, or "" if there is none.
+func shadowingGo(gobin string) string {
+	p, err := exec.LookPath("go")
+	if err != nil || sameFile(p, gobin) {
+		return ""
+	}
+	return p
+}
+
+// sameFile reports whether the paths a and b name the same file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
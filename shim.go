@@ -0,0 +1,103 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// shimMarker identifies the launchers written by writeShims, which may
+// be overwritten.
+const shimMarker = "Written by goup use -shim"
+
+// A shim looks up the active version each time it runs, as
+// install.ActiveVersion does: the command linked from <root>/bin, or
+// else the one of the version in <root>/default.
+
+const unixShim = `#!/bin/sh
+# ` + shimMarker + `; runs %[2]s of the active goup version.
+root=%[1]s
+cmd="$root/bin/%[2]s"
+if [ ! -x "$cmd" ] && [ -r "$root/default" ]; then
+	cmd="$root/$(cat "$root/default")/bin/%[2]s"
+fi
+if [ ! -x "$cmd" ]; then
+	echo "%[2]s: no active goup version; select one with 'goup use goX.Y.Z'" >&2
+	exit 1
+fi
+exec "$cmd" "$@"
+`
+
+const windowsShim = "@echo off\r\n" +
+	"rem " + shimMarker + "; runs %[2]s of the active goup version.\r\n" +
+	"setlocal\r\n" +
+	"set \"root=%[1]s\"\r\n" +
+	"if exist \"%%root%%\\bin\\%[2]s.cmd\" (\r\n" +
+	"\tcall \"%%root%%\\bin\\%[2]s.cmd\" %%*\r\n" +
+	"\texit /b\r\n" +
+	")\r\n" +
+	"if exist \"%%root%%\\default\" set /p version=<\"%%root%%\\default\"\r\n" +
+	"if not defined version (\r\n" +
+	"\techo %[2]s: no active goup version; select one with 'goup use goX.Y.Z' 1>&2\r\n" +
+	"\texit /b 1\r\n" +
+	")\r\n" +
+	"\"%%root%%\\%%version%%\\bin\\%[2]s.exe\" %%*\r\n"
+
+// writeShims writes launchers for the commands in activeLinks into dir,
+// which run the active version of the toolchains under root. They let a
+// directory already in PATH, such as ~/bin, switch with goup use
+// without adding <root>/bin to PATH.
+func writeShims(root, dir string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	if sameFile(dir, filepath.Join(root, "bin")) {
+		return fmt.Errorf("%v holds the links to the active version; choose another directory for the shims", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range activeLinks {
+		file, shim := filepath.Join(dir, name), fmt.Sprintf(unixShim, shellQuote(root), name)
+		if runtime.GOOS == "windows" {
+			file, shim = file+".cmd", fmt.Sprintf(windowsShim, root, name)
+		}
+		if data, err := os.ReadFile(file); err == nil && !bytes.Contains(data, []byte(shimMarker)) {
+			return fmt.Errorf("%v exists and was not written by goup; remove it to write a shim there", file)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := replaceFile(file, func(tmp string) error {
+			return os.WriteFile(tmp, []byte(shim), 0o755)
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %v, which runs %v of the active version.\n", file, name)
+	}
+	gobin := filepath.Join(dir, "go")
+	if runtime.GOOS == "windows" {
+		gobin += ".cmd"
+	}
+	switch {
+	case !inPath(dir):
+		warnf("%v is not in PATH, so the shims will not be found; add it to PATH.\n", dir)
+	case shadowingGo(gobin) != "":
+		warnf("%v comes before %v in PATH, so the shims will not be used.\n", shadowingGo(gobin), dir)
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
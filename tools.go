@@ -0,0 +1,129 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// toolResult is the JSON output for one of the -tools.
+type toolResult struct {
+	Tool    string `json:"tool"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// parseTools splits the -tools flag into module@version specs.
+func parseTools(s string) ([]string, error) {
+	var tools []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if path, version, ok := strings.Cut(t, "@"); !ok || path == "" || version == "" {
+			return nil, fmt.Errorf("invalid tool %q in -tools: want module@version", t)
+		}
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+// goCommand returns a command running the go command gobin with args.
+// It is made to use its own toolchain, whatever GOROOT and GOTOOLCHAIN
+// are set to in the environment.
+func goCommand(ctx context.Context, gobin string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, gobin, args...)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOROOT=") && !strings.HasPrefix(kv, "GOTOOLCHAIN=") {
+			c.Env = append(c.Env, kv)
+		}
+	}
+	c.Env = append(c.Env, "GOTOOLCHAIN=local")
+	c.Stdout = out
+	c.Stderr = os.Stderr
+	return c
+}
+
+// runGo is like goCommand, but captures the output of the command
+// instead of streaming it, and returns it for the caller to show or not.
+func runGo(ctx context.Context, gobin string, args ...string) (stdout, stderr string, err error) {
+	var outBuf, errBuf bytes.Buffer
+	c := goCommand(ctx, gobin, args...)
+	c.Stdout = &outBuf
+	c.Stderr = &errBuf
+	err = c.Run()
+	return outBuf.String(), errBuf.String(), err
+}
+
+// postInstallResult is the JSON output for the -post-install-cmd.
+type postInstallResult struct {
+	Command  string `json:"command"`
+	ExitCode int    `json:"exitCode"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// runPostInstall runs gobin with the arguments in cmdline, a go
+// subcommand line such as "install -race std", and shows its output.
+func runPostInstall(ctx context.Context, gobin, cmdline string) (*postInstallResult, error) {
+	args := strings.Fields(cmdline)
+	res := &postInstallResult{Command: "go " + strings.Join(args, " ")}
+	logf("Running %v...\n", res.Command)
+	stdout, stderr, err := runGo(ctx, gobin, args...)
+	res.Stdout, res.Stderr = stdout, stderr
+	if !jsonOutput {
+		fmt.Fprint(out, stdout)
+		fmt.Fprint(os.Stderr, stderr)
+	}
+	if err != nil {
+		res.ExitCode = -1
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			res.ExitCode = ee.ExitCode()
+		}
+		res.Error = err.Error()
+		return res, fmt.Errorf("%v: %v", res.Command, err)
+	}
+	return res, nil
+}
+
+// installTools runs go install for each of tools with gobin, carrying on
+// after failures. It returns an error naming the tools that failed.
+func installTools(ctx context.Context, gobin string, tools []string) ([]toolResult, error) {
+	var (
+		results []toolResult
+		failed  []string
+	)
+	for _, t := range tools {
+		logf("Installing %v...\n", t)
+		_, stderr, err := runGo(ctx, gobin, "install", t)
+		if err != nil {
+			if msg := strings.TrimSpace(stderr); msg != "" {
+				err = fmt.Errorf("%v\n%s", err, msg)
+			}
+			fmt.Fprintf(os.Stderr, "goup: go install %v: %v\n", t, err)
+			failed = append(failed, t)
+			results = append(results, toolResult{Tool: t, Error: err.Error()})
+			continue
+		}
+		// go install only reports progress, such as downloads, on stderr.
+		if stderr != "" {
+			logf("%s", stderr)
+		}
+		results = append(results, toolResult{Tool: t, Success: true})
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("failed to install %v", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/hyangah/goup/install"
+)
+
+var (
+	uninstallFlags    = flag.NewFlagSet("goup uninstall", flag.ExitOnError)
+	uninstallVersion  = uninstallFlags.String("version", "", "Go version to remove (e.g. go1.22.3). To remove several versions, pass them as arguments instead.")
+	uninstallAll      = uninstallFlags.Bool("all", false, "Remove all toolchains installed by goup, for any platform. This always asks for confirmation, even with -y.")
+	uninstallOS       = uninstallFlags.String("os", "", "GOOS of the toolchain to remove. Defaults to the host operating system.")
+	uninstallArch     = uninstallFlags.String("arch", "", "GOARCH of the toolchain to remove. Defaults to the host architecture.")
+	uninstallPlatform = uninstallFlags.String("platform", "", "GOOS/GOARCH of the toolchain to remove, e.g. linux/amd64. Shorthand for -os and -arch.")
+)
+
+func init() {
+	addQuietFlags(uninstallFlags)
+	addColorFlag(uninstallFlags)
+	addDirFlag(uninstallFlags)
+}
+
+// runUninstall implements the uninstall command. The versions to remove
+// are given by the -version flag or as arguments, for the platform given
+// by -os and -arch or -platform; -all removes every toolchain installed
+// by goup, including those for other platforms.
+func runUninstall(ctx context.Context, args []string) error {
+	uninstallFlags.Parse(args)
+
+	versions := uninstallFlags.Args()
+	if *uninstallVersion != "" {
+		if len(versions) > 0 {
+			return usageError("-version cannot be combined with version arguments")
+		}
+		versions = []string{*uninstallVersion}
+	}
+	switch {
+	case *uninstallAll && len(versions) > 0:
+		return usageError("-all cannot be combined with -version or version arguments")
+	case !*uninstallAll && len(versions) == 0:
+		return usageError("usage: goup uninstall goX.Y.Z... or goup uninstall -all")
+	case *uninstallAll && (*uninstallOS != "" || *uninstallArch != "" || *uninstallPlatform != ""):
+		return usageError("-all removes the toolchains for every platform, and cannot be combined with -os, -arch or -platform")
+	}
+	goos, goarch, err := parsePlatform(*uninstallOS, *uninstallArch, *uninstallPlatform)
+	if err != nil {
+		return err
+	}
+	hostOS, hostArch, err := install.HostOSArch()
+	if err != nil {
+		return err
+	}
+	if goos == "" {
+		goos = hostOS
+	}
+	if goarch == "" {
+		goarch = hostArch
+	}
+
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	for _, v := range versions {
+		if v == "tip" {
+			v = install.Tip
+		}
+		if err := install.ValidateVersion(v); err != nil {
+			return err
+		}
+		dir := install.VersionDir(root, v)
+		if goos != hostOS || goarch != hostArch {
+			dir = install.PlatformDir(root, v, goos, goarch)
+		}
+		if !install.IsManaged(dir) {
+			return fmt.Errorf("%v was not installed by goup; refusing to remove it", dir)
+		}
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if *uninstallAll {
+		installs, err := install.InstalledVersions(root)
+		if err != nil {
+			return err
+		}
+		for _, in := range installs {
+			if install.IsManaged(in.Dir) {
+				dirs = append(dirs, in.Dir)
+			}
+		}
+		if len(dirs) == 0 {
+			return fmt.Errorf("no Go installations managed by goup found in %v", root)
+		}
+	}
+	// Removing everything is too easy to get wrong to be done without
+	// asking, so -y does not skip the question then.
+	if (!quiet || *uninstallAll) && !promptYesNo(fmt.Sprintf("Remove %v?", strings.Join(dirs, ", ")), false) {
+		fmt.Println("Stopping go uninstallation.")
+		return nil
+	}
+
+	return removeInstallations(root, dirs)
+}
+
+// removeInstallations removes the toolchains in dirs, which must be
+// managed by goup, and reports the space freed.
+func removeInstallations(root string, dirs []string) error {
+	var total int64
+	for _, dir := range dirs {
+		if !install.IsManaged(dir) {
+			return fmt.Errorf("%v was not installed by goup; refusing to remove it", dir)
+		}
+		size, err := dirSize(dir)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		total += size
+		fmt.Printf("Removed %v (%s freed).\n", dir, formatBytes(size))
+	}
+	if len(dirs) > 1 {
+		fmt.Printf("%s freed in total.\n", formatBytes(total))
+	}
+	if slices.Contains(dirs, install.VersionDir(root, install.Tip)) {
+		// Remove the link made by setTipLink.
+		name := install.Tip
+		if runtime.GOOS == "windows" {
+			name += ".cmd"
+		}
+		os.Remove(filepath.Join(root, "bin", name))
+	}
+	return fallBackToDefault(root, dirs)
+}
+
+// fallBackToDefault forgets the default version if it was among the
+// removed dirs, and points the links in <root>/bin at the default
+// version if the selected one was removed.
+func fallBackToDefault(root string, removed []string) error {
+	if version, err := install.DefaultVersion(root); err == nil && version != "" && slices.Contains(removed, install.VersionDir(root, version)) {
+		if err := install.SetDefaultVersion(root, ""); err != nil {
+			return err
+		}
+	}
+	selected, err := install.SelectedDir(root)
+	if err != nil || !slices.Contains(removed, selected) {
+		return err
+	}
+	// With the selected version gone, the default one is active.
+	active, err := install.ActiveVersion(root)
+	if err != nil {
+		return err
+	}
+	dir := active.Dir
+	if dir == "" {
+		clearActive(root)
+		return nil
+	}
+	if err := setActive(root, dir); err != nil {
+		return err
+	}
+	fmt.Printf("Now using the default version, %v.\n", filepath.Base(dir))
+	return nil
+}
+
+// dirSize returns the total size of the regular files in dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
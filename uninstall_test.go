@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyangah/goup/install"
+)
+
+// installFake installs a toolchain for version and goos/goarch into root
+// from a zip, whose go command does not run, and returns its directory.
+func installFake(t *testing.T, root, version, goos, goarch string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "go.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for name, body := range map[string]string{"VERSION": version + "\n", "bin/go": "", "bin/go.exe": ""} {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(body))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := install.Install(context.Background(), install.Options{Dir: root, From: file, GOOS: goos, GOARCH: goarch, Insecure: true, SkipRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Dir
+}
+
+func TestUninstallCross(t *testing.T) {
+	t.Cleanup(func() {
+		dirFlag, quiet, *uninstallAll, *uninstallPlatform = "", false, false, ""
+		stdin = bufio.NewReader(os.Stdin)
+	})
+	hostOS, hostArch, err := install.HostOSArch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	goos, goarch := "windows", "arm64"
+	if hostOS == goos {
+		goos = "linux"
+	}
+	root := t.TempDir()
+	host := installFake(t, root, "go1.22.3", hostOS, hostArch)
+	cross := installFake(t, root, "go1.22.3", goos, goarch)
+	if cross != install.PlatformDir(root, "go1.22.3", goos, goarch) {
+		t.Fatalf("cross toolchain installed in %v, want its PlatformDir", cross)
+	}
+	exists := func(dir string) bool {
+		_, err := os.Stat(dir)
+		return err == nil
+	}
+
+	// -platform selects the cross toolchain, and leaves the host's.
+	if err := runUninstall(context.Background(), []string{"-dir", root, "-y", "-platform", goos + "/" + goarch, "go1.22.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(cross) || !exists(host) {
+		t.Errorf("after uninstalling for %v/%v: cross exists = %v, host exists = %v; want false, true", goos, goarch, exists(cross), exists(host))
+	}
+
+	// -all removes toolchains for every platform.
+	cross = installFake(t, root, "go1.22.3", goos, goarch)
+	stdin = bufio.NewReader(strings.NewReader("y\n"))
+	if err := runUninstall(context.Background(), []string{"-dir", root, "-platform=", "-all"}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(cross) || exists(host) {
+		t.Errorf("after uninstall -all: cross exists = %v, host exists = %v; want neither", exists(cross), exists(host))
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/hyangah/goup/install"
+)
+
+var (
+	useFlags   = flag.NewFlagSet("goup use", flag.ExitOnError)
+	useDefault = useFlags.Bool("default", false, "Also record the version as the default, used when no version is selected.")
+	useShim    = useFlags.String("shim", "", "Also write go and gofmt launchers into `dir`, a directory in PATH such as ~/bin, that run the active version.")
+)
+
+func init() {
+	addDirFlag(useFlags)
+	addColorFlag(useFlags)
+}
+
+// runUse implements the use command.
+//
+//	goup use [-default] [-shim dir] goX.Y.Z
+//
+// selects the active version by pointing <root>/bin/go at that version's
+// go command. With -default, the version also becomes the default. With
+// -shim, launchers of the active version are written into dir (see
+// writeShims). With no arguments, it prints the active version, or only
+// writes the launchers if -shim is set.
+func runUse(ctx context.Context, args []string) error {
+	useFlags.Parse(args)
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+
+	switch useFlags.NArg() {
+	case 0:
+		if *useShim != "" {
+			return writeShims(root, *useShim)
+		}
+		dir, err := activeDir(root)
+		if err != nil {
+			return err
+		}
+		if dir == "" {
+			fmt.Println("No active Go version. Select one with 'goup use goX.Y.Z'.")
+			return nil
+		}
+		if selected, err := install.SelectedDir(root); err == nil && selected != dir {
+			fmt.Printf("%v (%v, the default)\n", filepath.Base(dir), dir)
+			return nil
+		}
+		fmt.Printf("%v (%v)\n", filepath.Base(dir), dir)
+	case 1:
+		version := useFlags.Arg(0)
+		if version == "tip" {
+			version = install.Tip
+		}
+		if err := install.ValidateVersion(version); err != nil {
+			return err
+		}
+		dir := install.VersionDir(root, version)
+		if _, err := os.Stat(install.GoBinary(dir)); err != nil {
+			return fmt.Errorf("%v is not installed; install it with 'goup install -version %v'", version, version)
+		}
+		if err := switchActive(root, dir); err != nil {
+			return fmt.Errorf("cannot use %v: %v", version, err)
+		}
+		fmt.Printf("Now using %v.\n", version)
+		if *useDefault {
+			if err := install.SetDefaultVersion(root, version); err != nil {
+				return err
+			}
+			fmt.Printf("%v is the default version.\n", version)
+		}
+		if *useShim != "" {
+			return writeShims(root, *useShim)
+		}
+		logf("Make sure %v is in your PATH.\n", filepath.Join(root, "bin"))
+	default:
+		return usageError("use takes at most one version: goup use [-default] [-shim dir] [goX.Y.Z]")
+	}
+	return nil
+}
+
+// activeLinks returns the commands that are linked from <root>/bin
+// into the active toolchain.
+var activeLinks = []string{"go", "gofmt"}
+
+// setActive makes the toolchain in dir the active one, by linking the
+// commands in <root>/bin to it. On Windows, where symlinks usually
+// require elevated privileges, small batch file shims are written instead.
+// Each link or shim is created under a temporary name and renamed over
+// the old one, so that a go command running meanwhile always finds one.
+func setActive(root, dir string) error {
+	bin := filepath.Join(root, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		return err
+	}
+	for _, name := range activeLinks {
+		target := filepath.Join(dir, "bin", name)
+		if runtime.GOOS == "windows" {
+			shim := fmt.Sprintf("@\"%s.exe\" %%*\r\n", target)
+			if err := replaceFile(filepath.Join(bin, name+".cmd"), func(tmp string) error {
+				return os.WriteFile(tmp, []byte(shim), 0o644)
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		link := filepath.Join(bin, name)
+		if _, err := os.Stat(target); err != nil {
+			// e.g. the bootstrap toolchain has no gofmt
+			if err := os.Remove(link); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		if err := replaceFile(link, func(tmp string) error {
+			return os.Symlink(target, tmp)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTipLink links the gotip command in <root>/bin to the go command of
+// the Tip toolchain in dir, as setActive does, and returns its path.
+func setTipLink(root, dir string) (string, error) {
+	bin := filepath.Join(root, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, "bin", "go")
+	if runtime.GOOS == "windows" {
+		link := filepath.Join(bin, install.Tip+".cmd")
+		shim := fmt.Sprintf("@\"%s.exe\" %%*\r\n", target)
+		return link, replaceFile(link, func(tmp string) error {
+			return os.WriteFile(tmp, []byte(shim), 0o644)
+		})
+	}
+	link := filepath.Join(bin, install.Tip)
+	return link, replaceFile(link, func(tmp string) error {
+		return os.Symlink(target, tmp)
+	})
+}
+
+// tmpSeq numbers the temporary files of replaceFile, so that calls from
+// several goroutines do not collide.
+var tmpSeq atomic.Int64
+
+// replaceFile atomically replaces file with the one create makes at the
+// temporary path it is given, in the same directory.
+func replaceFile(file string, create func(tmp string) error) error {
+	tmp := filepath.Join(filepath.Dir(file), fmt.Sprintf(".%s.%d-%d.tmp", filepath.Base(file), os.Getpid(), tmpSeq.Add(1)))
+	os.Remove(tmp) // left by an interrupted goup
+	if err := create(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// switchActive makes the toolchain in dir the active one and checks that
+// the go command in <root>/bin then runs. If it does not, the previously
+// active toolchain, if any, is restored.
+func switchActive(root, dir string) error {
+	prev, err := install.SelectedDir(root)
+	if err != nil {
+		return err
+	}
+	err = setActive(root, dir)
+	if err == nil {
+		gobin := filepath.Join(root, "bin", "go")
+		if runtime.GOOS == "windows" {
+			gobin += ".cmd"
+		}
+		if _, verr := install.GoVersion(gobin); verr != nil {
+			err = fmt.Errorf("its go command does not run: %v", verr)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	if prev == "" {
+		clearActive(root)
+		return err
+	}
+	if rerr := setActive(root, prev); rerr != nil {
+		return fmt.Errorf("%v; restoring %v also failed: %v", err, prev, rerr)
+	}
+	return fmt.Errorf("%v; still using %v", err, filepath.Base(prev))
+}
+
+// clearActive removes the links made by setActive.
+func clearActive(root string) {
+	for _, name := range activeLinks {
+		if runtime.GOOS == "windows" {
+			name += ".cmd"
+		}
+		os.Remove(filepath.Join(root, "bin", name))
+	}
+}
+
+// activeDir returns the directory of the active toolchain, or "" if
+// there is none. See install.ActiveVersion.
+func activeDir(root string) (string, error) {
+	in, err := install.ActiveVersion(root)
+	return in.Dir, err
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// fakeToolchain writes a toolchain into root/version whose go command
+// is a shell script reporting version.
+func fakeToolchain(t *testing.T, root, version string) string {
+	t.Helper()
+	dir := filepath.Join(root, version)
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range activeLinks {
+		script := fmt.Sprintf("#!/bin/sh\necho go version %s %s/%s\n", version, runtime.GOOS, runtime.GOARCH)
+		if err := os.WriteFile(filepath.Join(dir, "bin", name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSwitchActiveConcurrent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake go commands are shell scripts")
+	}
+	root := t.TempDir()
+	dirs := []string{fakeToolchain(t, root, "go1.21.0"), fakeToolchain(t, root, "go1.22.3")}
+	if err := switchActive(root, dirs[0]); err != nil {
+		t.Fatal(err)
+	}
+	gobin := filepath.Join(root, "bin", "go")
+
+	// While goroutines switch back and forth, bin/go must always
+	// resolve to one of the toolchains.
+	done := make(chan struct{})
+	watched := make(chan int)
+	go func() {
+		n := 0
+		defer func() { watched <- n }()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			target, err := filepath.EvalSymlinks(gobin)
+			if err != nil {
+				t.Errorf("bin/go does not resolve: %v", err)
+				return
+			}
+			if dir := filepath.Dir(filepath.Dir(target)); dir != dirs[0] && dir != dirs[1] {
+				t.Errorf("bin/go resolves to %s", target)
+				return
+			}
+			n++
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := switchActive(root, dir); err != nil {
+					t.Errorf("switchActive(%s): %v", filepath.Base(dir), err)
+					return
+				}
+			}
+		}(dirs[i%2])
+	}
+	wg.Wait()
+	close(done)
+	if n := <-watched; n == 0 {
+		t.Errorf("bin/go was never checked")
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Join(root, "bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(activeLinks) {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("bin holds %q, want only %q", names, activeLinks)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hyangah/goup/install"
+)
+
+var (
+	verifyFlags   = flag.NewFlagSet("goup verify", flag.ExitOnError)
+	verifyVersion = verifyFlags.String("version", "", "Go version to verify (e.g. go1.22.3). If empty, all installed versions are verified.")
+)
+
+func init() {
+	addDirFlag(verifyFlags)
+	addColorFlag(verifyFlags)
+}
+
+// runVerify implements the verify command.
+func runVerify(ctx context.Context, args []string) error {
+	verifyFlags.Parse(args)
+
+	root, err := installDir()
+	if err != nil {
+		return err
+	}
+	var installs []install.Installation
+	if v := *verifyVersion; v != "" {
+		if v == "tip" {
+			v = install.Tip
+		}
+		if err := install.ValidateVersion(v); err != nil {
+			return err
+		}
+		dir := install.VersionDir(root, v)
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("%v is not installed in %v", v, root)
+		}
+		installs = append(installs, install.Installation{Version: v, Dir: dir})
+	} else {
+		all, err := install.InstalledVersions(root)
+		if err != nil {
+			return err
+		}
+		// Toolchains for other platforms cannot run here to be verified.
+		for _, in := range all {
+			if in.GOOS == "" {
+				installs = append(installs, in)
+			}
+		}
+		if len(installs) == 0 {
+			return fmt.Errorf("no Go installations found in %v", root)
+		}
+		for i, in := range installs {
+			// Without a manifest, the version comes from the go command
+			// being verified, so check it against the directory name.
+			if !install.IsManaged(in.Dir) {
+				installs[i].Version = filepath.Base(in.Dir)
+			}
+		}
+	}
+
+	failed := 0
+	for _, in := range installs {
+		dir := in.Dir
+		problems := install.Verify(dir, in.Version)
+		if len(problems) == 0 {
+			fmt.Printf("%v: ok\n", dir)
+			continue
+		}
+		failed++
+		fmt.Printf("%v: FAILED\n", dir)
+		for _, p := range problems {
+			fmt.Printf("\t%v\n", p)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d installations failed verification", failed, len(installs))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/hyangah/goup/install"
+)
+
+// commit and date identify the build of goup. Release builds set them,
+// and install.Version, with -ldflags (see .goreleaser.yaml). Otherwise
+// they are taken from the version control information recorded by go
+// build, if any.
+var commit, date string
+
+var versionFlags = flag.NewFlagSet("goup version", flag.ExitOnError)
+
+func init() {
+	addJSONFlag(versionFlags)
+}
+
+// versionResult is the JSON output of the version command.
+type versionResult struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GOOS      string `json:"goos"`   // of the host, which may differ
+	GOARCH    string `json:"goarch"` // from those goup was built for
+	GoVersion string `json:"goVersion"`
+}
+
+// runVersion implements the version command, which prints the version
+// of goup, for bug reports.
+func runVersion(ctx context.Context, args []string) error {
+	versionFlags.Parse(args)
+	if versionFlags.NArg() != 0 {
+		return usageError("usage: goup version [-json]")
+	}
+	goos, goarch, err := install.HostOSArch()
+	if err != nil {
+		return err
+	}
+	v := versionResult{Version: install.Version, Commit: commit, Date: date, GOOS: goos, GOARCH: goarch, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && v.Commit == "":
+				v.Commit = s.Value
+			case s.Key == "vcs.time" && v.Date == "":
+				v.Date = s.Value
+			}
+		}
+	}
+	if jsonOutput {
+		return writeJSON(v)
+	}
+	fmt.Printf("goup version %v %v/%v", v.Version, v.GOOS, v.GOARCH)
+	if v.Commit != "" {
+		fmt.Printf(" commit %v", v.Commit)
+	}
+	if v.Date != "" {
+		fmt.Printf(" built %v", v.Date)
+	}
+	fmt.Printf(" with %v\n", v.GoVersion)
+	return nil
+}